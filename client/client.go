@@ -28,9 +28,16 @@ func New(coder coder.Coder, client *http.Client) Client {
 // To add additional data to the request, use the optional function f.
 func (c *protoClient) Request(ctx context.Context, method, url string, body any, f func(*http.Request)) (*http.Response, error) {
 	var reader io.Reader
+	contentType := c.ContentType()
 	if body != nil {
 		buf := new(bytes.Buffer)
-		if err := c.Encode(buf, body); err != nil {
+		var err error
+		if e, ok := c.Coder.(coder.MediaTypeEncoder); ok {
+			contentType, err = e.EncodeMediaType(buf, body)
+		} else {
+			err = c.Encode(buf, body)
+		}
+		if err != nil {
 			return nil, err
 		}
 		reader = buf
@@ -42,8 +49,8 @@ func (c *protoClient) Request(ctx context.Context, method, url string, body any,
 	}
 
 	if reader != nil {
-		if t := c.ContentType(); t != "" {
-			request.Header.Set(coder.ContentType, t)
+		if contentType != "" {
+			request.Header.Set(coder.ContentType, contentType)
 		}
 	}
 
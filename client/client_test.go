@@ -157,3 +157,20 @@ func TestProtoClient_ContentType(t *testing.T) {
 		})
 	}
 }
+
+func TestProtoClient_RequestMultipart(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equal(t, nil, r.ParseMultipartForm(1<<20))
+		equal(t, "example", r.FormValue("field"))
+	}))
+	defer ts.Close()
+
+	clt := client.New(coder.NewMultipartCoder(nil), http.DefaultClient)
+
+	resp, err := clt.Request(context.Background(), http.MethodPost, ts.URL, &struct {
+		Field string `form:"field"`
+	}{Field: "example"}, nil)
+	equal(t, nil, err)
+	defer resp.Body.Close()
+	equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gromey/proto-rest/coder"
+)
+
+// Event represents a received Server-Sent Event.
+type Event struct {
+	ID    string        // The last event ID, it carries over from previous events.
+	Event string        // The event type. Defaults to "message".
+	Retry time.Duration // The reconnection time sent by the server, zero if not sent with this event.
+	Data  []byte        // The raw event data, multiple data lines are joined with newlines.
+}
+
+// EventReader reads Server-Sent Events from a text/event-stream response.
+type EventReader struct {
+	coder.Coder
+	resp   *http.Response
+	r      *bufio.Reader
+	lastID string
+}
+
+// Events sends a GET request to url with c and returns a reader of the event stream.
+// To resume a stream, set the Last-Event-ID header with the optional function f.
+// The stream ends when ctx is done; the reader must be closed.
+func Events(ctx context.Context, c Client, url string, f func(*http.Request)) (*EventReader, error) {
+	var lastID string
+	resp, err := c.Request(ctx, http.MethodGet, url, nil, func(r *http.Request) {
+		r.Header.Set("Accept", "text/event-stream")
+		r.Header.Set("Cache-Control", "no-cache")
+		if f != nil {
+			f(r)
+		}
+		lastID = r.Header.Get("Last-Event-ID")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("client: unexpected event stream status: %s", resp.Status)
+	}
+	if t, _, _ := mime.ParseMediaType(resp.Header.Get(coder.ContentType)); t != "text/event-stream" {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("client: unexpected event stream content type: %q", resp.Header.Get(coder.ContentType))
+	}
+
+	return &EventReader{Coder: c, resp: resp, r: bufio.NewReader(resp.Body), lastID: lastID}, nil
+}
+
+// Next blocks until the next event is received. It returns io.EOF when the stream ends,
+// or the context error when the context is done.
+func (er *EventReader) Next() (*Event, error) {
+	ev := new(Event)
+	var data bytes.Buffer
+	var hasData bool
+
+	for {
+		line, err := er.r.ReadString('\n')
+		if err != nil {
+			if ctxErr := er.resp.Request.Context().Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			// An event that is not terminated by a blank line is discarded.
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if !hasData {
+				ev = &Event{}
+				continue
+			}
+			ev.ID = er.lastID
+			if ev.Event == "" {
+				ev.Event = "message"
+			}
+			ev.Data = bytes.TrimSuffix(data.Bytes(), []byte("\n"))
+			return ev, nil
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			ev.Event = value
+		case "data":
+			hasData = true
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				er.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				ev.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// DecodeEvent decodes the data of the event into the value pointed to by v with the Coder.
+func (er *EventReader) DecodeEvent(ev *Event, v any) error {
+	return er.Decode(bytes.NewReader(ev.Data), v)
+}
+
+// LastEventID returns the ID of the last event received, to resume the stream after reconnecting.
+func (er *EventReader) LastEventID() string {
+	return er.lastID
+}
+
+// Close closes the response body.
+func (er *EventReader) Close() error {
+	return er.resp.Body.Close()
+}
+
+// NextEvent reads the next event from er and decodes its data into a value of type T.
+func NextEvent[T any](er *EventReader) (*T, *Event, error) {
+	ev, err := er.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	v := new(T)
+	if err = er.DecodeEvent(ev, v); err != nil {
+		return nil, ev, err
+	}
+	return v, ev, nil
+}
@@ -0,0 +1,63 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/client"
+)
+
+func TestEvents(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equal(t, "text/event-stream", r.Header.Get("Accept"))
+		equal(t, "7", r.Header.Get("Last-Event-ID"))
+
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		_, _ = io.WriteString(w, ": comment\n\n"+
+			"id: 8\nevent: update\nretry: 1500\ndata: {\"Field\":\ndata: \"a\"}\n\n"+
+			"data:{\"Field\":\"b\"}\r\n\r\n"+
+			"data: {\"Field\":\"unterminated\"}\n")
+	}))
+	defer ts.Close()
+
+	clt := client.New(cdrJSON, http.DefaultClient)
+
+	er, err := client.Events(context.Background(), clt, ts.URL, func(r *http.Request) {
+		r.Header.Set("Last-Event-ID", "7")
+	})
+	equal(t, nil, err)
+	defer er.Close()
+
+	equal(t, "7", er.LastEventID())
+
+	v, ev, err := client.NextEvent[exampleStructClt](er)
+	equal(t, nil, err)
+	equal(t, &exampleStructClt{Field: "a"}, v)
+	equal(t, &client.Event{ID: "8", Event: "update", Retry: 1500 * time.Millisecond, Data: []byte("{\"Field\":\n\"a\"}")}, ev)
+
+	v, ev, err = client.NextEvent[exampleStructClt](er)
+	equal(t, nil, err)
+	equal(t, &exampleStructClt{Field: "b"}, v)
+	equal(t, "8", ev.ID)
+	equal(t, "message", ev.Event)
+
+	_, err = er.Next()
+	equal(t, io.EOF, err)
+}
+
+func TestEvents_UnexpectedResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, "{}")
+	}))
+	defer ts.Close()
+
+	clt := client.New(cdrJSON, http.DefaultClient)
+
+	_, err := client.Events(context.Background(), clt, ts.URL, nil)
+	equal(t, true, err != nil)
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gromey/proto-rest/coder"
+)
+
+// DefaultMaxItemSize is the maximum size of an item read by a StreamReader created with NewStreamReader.
+const DefaultMaxItemSize = 1 << 20
+
+// StreamReader decodes the items of a newline-delimited (NDJSON, JSON Lines) body one by one.
+type StreamReader struct {
+	coder.Coder
+	ctx     context.Context
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// NewStreamReader returns a StreamReader that decodes the items of body with c.
+// Reading fails once ctx is done, or with bufio.ErrTooLong when an item is larger than DefaultMaxItemSize.
+func NewStreamReader(ctx context.Context, c coder.Coder, body io.ReadCloser) *StreamReader {
+	return NewStreamReaderSize(ctx, c, body, DefaultMaxItemSize)
+}
+
+// NewStreamReaderSize returns a StreamReader like NewStreamReader that reads items of up to maxItemSize bytes.
+func NewStreamReaderSize(ctx context.Context, c coder.Coder, body io.ReadCloser, maxItemSize int) *StreamReader {
+	size := 4096
+	if maxItemSize < size {
+		size = maxItemSize
+	}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, size), maxItemSize)
+	return &StreamReader{Coder: c, ctx: ctx, body: body, scanner: scanner}
+}
+
+// Stream sends an HTTP request with c like Request and returns a reader of the newline-delimited response body.
+// It fails if the response status is not 2xx. The reader must be closed.
+func Stream(ctx context.Context, c Client, method, url string, body any, f func(*http.Request)) (*StreamReader, error) {
+	resp, err := c.Request(ctx, method, url, body, func(r *http.Request) {
+		r.Header.Set("Accept", "application/x-ndjson")
+		if f != nil {
+			f(r)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("client: unexpected stream status: %s", resp.Status)
+	}
+
+	return NewStreamReader(ctx, c, resp.Body), nil
+}
+
+// Next decodes the next item into the value pointed to by v. Blank lines are skipped.
+// It returns io.EOF when the stream ends, or the context error when the context is done.
+func (sr *StreamReader) Next(v any) error {
+	for {
+		if err := sr.ctx.Err(); err != nil {
+			return err
+		}
+
+		if !sr.scanner.Scan() {
+			if ctxErr := sr.ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err := sr.scanner.Err(); err != nil {
+				return err
+			}
+			return io.EOF
+		}
+
+		if item := bytes.TrimSpace(sr.scanner.Bytes()); len(item) != 0 {
+			return sr.Decode(bytes.NewReader(item), v)
+		}
+	}
+}
+
+// Close closes the body.
+func (sr *StreamReader) Close() error {
+	return sr.body.Close()
+}
+
+// NextItem decodes the next item of sr into a value of type T.
+func NextItem[T any](sr *StreamReader) (*T, error) {
+	v := new(T)
+	if err := sr.Next(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
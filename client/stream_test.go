@@ -0,0 +1,75 @@
+package client_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gromey/proto-rest/client"
+)
+
+func TestStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equal(t, "application/x-ndjson", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = io.WriteString(w, "{\"Field\":\"a\"}\n\n{\"Field\":\"b\"}\r\n{\"Field\":\"c\"}")
+	}))
+	defer ts.Close()
+
+	clt := client.New(cdrJSON, http.DefaultClient)
+
+	sr, err := client.Stream(context.Background(), clt, http.MethodGet, ts.URL, nil, nil)
+	equal(t, nil, err)
+	defer sr.Close()
+
+	var items []string
+	for {
+		item, err := client.NextItem[exampleStructClt](sr)
+		if err == io.EOF {
+			break
+		}
+		equal(t, nil, err)
+		items = append(items, item.Field)
+	}
+	equal(t, []string{"a", "b", "c"}, items)
+}
+
+func TestStreamReader_ContextCanceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "{\"Field\":\"a\"}\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	clt := client.New(cdrJSON, http.DefaultClient)
+
+	sr, err := client.Stream(ctx, clt, http.MethodGet, ts.URL, nil, nil)
+	equal(t, nil, err)
+	defer sr.Close()
+
+	item, err := client.NextItem[exampleStructClt](sr)
+	equal(t, nil, err)
+	equal(t, "a", item.Field)
+
+	cancel()
+	_, err = client.NextItem[exampleStructClt](sr)
+	equal(t, context.Canceled, err)
+}
+
+func TestStreamReader_ItemTooLong(t *testing.T) {
+	body := io.NopCloser(strings.NewReader("{\"Field\":\"a\"}\n{\"Field\":\"" + strings.Repeat("b", 64) + "\"}\n"))
+	sr := client.NewStreamReaderSize(context.Background(), cdrJSON, body, 32)
+
+	item, err := client.NextItem[exampleStructClt](sr)
+	equal(t, nil, err)
+	equal(t, "a", item.Field)
+
+	_, err = client.NextItem[exampleStructClt](sr)
+	equal(t, bufio.ErrTooLong, err)
+}
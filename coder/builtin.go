@@ -0,0 +1,208 @@
+package coder
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Content types of the built-in coders.
+const (
+	JSONContentType        = "application/json"
+	XMLContentType         = "application/xml; charset=utf-8"
+	FormContentType        = "application/x-www-form-urlencoded"
+	TextContentType        = "text/plain; charset=utf-8"
+	OctetStreamContentType = "application/octet-stream"
+)
+
+// JSONOptions represents options for configuring the JSON Coder.
+type JSONOptions struct {
+	DisallowUnknownFields bool   // Decoding fails when an object has a key that doesn't match a field.
+	UseNumber             bool   // Numbers are decoded into an interface{} as json.Number instead of float64.
+	Indent                string // Encodes indented with this string, e.g. two spaces.
+}
+
+// NewJSONCoder returns a new Coder for application/json.
+func NewJSONCoder(opts *JSONOptions) Coder {
+	if opts == nil {
+		opts = new(JSONOptions)
+	}
+
+	marshal := json.Marshal
+	if opts.Indent != "" {
+		indent := opts.Indent
+		marshal = func(v any) ([]byte, error) {
+			return json.MarshalIndent(v, "", indent)
+		}
+	}
+
+	unmarshal := json.Unmarshal
+	if opts.DisallowUnknownFields || opts.UseNumber {
+		disallow, useNumber := opts.DisallowUnknownFields, opts.UseNumber
+		unmarshal = func(data []byte, v any) error {
+			d := json.NewDecoder(bytes.NewReader(data))
+			if disallow {
+				d.DisallowUnknownFields()
+			}
+			if useNumber {
+				d.UseNumber()
+			}
+			if err := d.Decode(v); err != nil {
+				return err
+			}
+			if _, err := d.Token(); err != io.EOF {
+				return errors.New("coder: invalid data after top-level JSON value")
+			}
+			return nil
+		}
+	}
+
+	return NewCoder(JSONContentType, marshal, unmarshal)
+}
+
+// NewXMLCoder returns a new Coder for application/xml. Encoded documents start with the XML declaration.
+func NewXMLCoder() Coder {
+	return NewCoder(XMLContentType, func(v any) ([]byte, error) {
+		p, err := xml.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), p...), nil
+	}, xml.Unmarshal)
+}
+
+// NewFormCoder returns a new Coder for application/x-www-form-urlencoded. It encodes and decodes structs
+// with fields named by the form tag, nested struct fields are named parent.child, and url.Values.
+func NewFormCoder() Coder {
+	return NewCoder(FormContentType, marshalForm, unmarshalForm)
+}
+
+func marshalForm(v any) ([]byte, error) {
+	switch x := v.(type) {
+	case url.Values:
+		return []byte(x.Encode()), nil
+	case *url.Values:
+		return []byte(x.Encode()), nil
+	}
+
+	rv, err := structValue(v, false)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(url.Values)
+	for _, f := range formFields(rv, "", nil) {
+		if f.omitEmpty && f.value.IsZero() {
+			continue
+		}
+		vs, err := formValues(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("coder: form field %q: %w", f.name, err)
+		}
+		values[f.name] = append(values[f.name], vs...)
+	}
+
+	return []byte(values.Encode()), nil
+}
+
+func unmarshalForm(data []byte, v any) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+
+	if x, ok := v.(*url.Values); ok {
+		*x = values
+		return nil
+	}
+
+	rv, err := structValue(v, true)
+	if err != nil {
+		return err
+	}
+
+	hasPrefix := func(prefix string) bool {
+		for k := range values {
+			if strings.HasPrefix(k, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, f := range formFields(rv, "", hasPrefix) {
+		if vs, ok := values[f.name]; ok {
+			if err = setFormValues(f.value, vs); err != nil {
+				return fmt.Errorf("coder: form field %q: %w", f.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// NewTextCoder returns a new Coder for text/plain. It encodes strings, byte slices, fmt.Stringer
+// and encoding.TextMarshaler values, and decodes into *string, *[]byte and encoding.TextUnmarshaler values.
+func NewTextCoder() Coder {
+	return NewCoder(TextContentType, func(v any) ([]byte, error) {
+		switch x := v.(type) {
+		case string:
+			return []byte(x), nil
+		case *string:
+			return []byte(*x), nil
+		case []byte:
+			return x, nil
+		case encoding.TextMarshaler:
+			return x.MarshalText()
+		case fmt.Stringer:
+			return []byte(x.String()), nil
+		}
+		return nil, fmt.Errorf("coder: can't encode %T as text", v)
+	}, func(data []byte, v any) error {
+		switch x := v.(type) {
+		case *string:
+			*x = string(data)
+			return nil
+		case *[]byte:
+			*x = append((*x)[:0], data...)
+			return nil
+		case encoding.TextUnmarshaler:
+			return x.UnmarshalText(data)
+		}
+		return fmt.Errorf("coder: can't decode text into %T", v)
+	})
+}
+
+// NewBytesCoder returns a new Coder for application/octet-stream. It encodes byte slices, strings
+// and io.Reader values as they are, and decodes into *[]byte and io.Writer values.
+func NewBytesCoder() Coder {
+	return NewCoder(OctetStreamContentType, func(v any) ([]byte, error) {
+		switch x := v.(type) {
+		case []byte:
+			return x, nil
+		case *[]byte:
+			return *x, nil
+		case string:
+			return []byte(x), nil
+		case io.Reader:
+			return io.ReadAll(x)
+		}
+		return nil, fmt.Errorf("coder: can't encode %T as bytes", v)
+	}, func(data []byte, v any) error {
+		switch x := v.(type) {
+		case *[]byte:
+			*x = append((*x)[:0], data...)
+			return nil
+		case io.Writer:
+			_, err := x.Write(data)
+			return err
+		}
+		return fmt.Errorf("coder: can't decode bytes into %T", v)
+	})
+}
@@ -0,0 +1,132 @@
+package coder_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/coder"
+)
+
+type address struct {
+	City string `form:"city" json:"city" xml:"city"`
+}
+
+type person struct {
+	Name    string    `form:"name" json:"name" xml:"name"`
+	Age     int       `form:"age,omitempty" json:"age,omitempty" xml:"age,omitempty"`
+	Emails  []string  `form:"email" json:"emails" xml:"email"`
+	Born    time.Time `form:"born" json:"born" xml:"born"`
+	Address *address  `form:"address" json:"address" xml:"address"`
+}
+
+func TestBuiltinCoders(t *testing.T) {
+	in := &person{
+		Name:    "Ann & Bob",
+		Emails:  []string{"a@example.com", "b@example.com"},
+		Born:    time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC),
+		Address: &address{City: "Zürich"},
+	}
+
+	var tests = []struct {
+		name        string
+		coder       coder.Coder
+		contentType string
+		encoded     string
+	}{
+		{
+			name:        "json",
+			coder:       coder.NewJSONCoder(nil),
+			contentType: "application/json",
+			encoded:     `{"name":"Ann \u0026 Bob","emails":["a@example.com","b@example.com"],"born":"2000-01-02T03:04:05Z","address":{"city":"Zürich"}}`,
+		},
+		{
+			name:        "xml",
+			coder:       coder.NewXMLCoder(),
+			contentType: "application/xml; charset=utf-8",
+			encoded:     "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<person><name>Ann &amp; Bob</name><email>a@example.com</email><email>b@example.com</email><born>2000-01-02T03:04:05Z</born><address><city>Zürich</city></address></person>",
+		},
+		{
+			name:        "form",
+			coder:       coder.NewFormCoder(),
+			contentType: "application/x-www-form-urlencoded",
+			encoded:     "address.city=Z%C3%BCrich&born=2000-01-02T03%3A04%3A05Z&email=a%40example.com&email=b%40example.com&name=Ann+%26+Bob",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			equal(t, test.contentType, test.coder.ContentType())
+
+			buf := new(bytes.Buffer)
+			equal(t, nil, test.coder.Encode(buf, in))
+			equal(t, test.encoded, buf.String())
+
+			out := new(person)
+			equal(t, nil, test.coder.Decode(buf, out))
+			equal(t, in, out)
+		})
+	}
+}
+
+func TestJSONCoder_Options(t *testing.T) {
+	strict := coder.NewJSONCoder(&coder.JSONOptions{DisallowUnknownFields: true, UseNumber: true})
+
+	err := strict.Decode(strings.NewReader(`{"field":"a","unknown":1}`), new(exampleStruct))
+	equal(t, `json: unknown field "unknown"`, err.Error())
+
+	err = strict.Decode(strings.NewReader(`{"field":"a"} {}`), new(exampleStruct))
+	equal(t, true, err != nil)
+
+	var v map[string]any
+	equal(t, nil, strict.Decode(strings.NewReader(`{"n":12345678901234567890}`), &v))
+	equal(t, json.Number("12345678901234567890"), v["n"])
+
+	buf := new(bytes.Buffer)
+	equal(t, nil, coder.NewJSONCoder(&coder.JSONOptions{Indent: "  "}).Encode(buf, &exampleStruct{Field: "a"}))
+	equal(t, "{\n  \"field\": \"a\"\n}", buf.String())
+}
+
+func TestFormCoder_Values(t *testing.T) {
+	fc := coder.NewFormCoder()
+
+	buf := new(bytes.Buffer)
+	equal(t, nil, fc.Encode(buf, url.Values{"b": {"2"}, "a": {"1"}}))
+	equal(t, "a=1&b=2", buf.String())
+
+	var values url.Values
+	equal(t, nil, fc.Decode(buf, &values))
+	equal(t, url.Values{"a": {"1"}, "b": {"2"}}, values)
+
+	err := fc.Decode(strings.NewReader("age=old"), new(person))
+	equal(t, `coder: form field "age": strconv.ParseInt: parsing "old": invalid syntax`, err.Error())
+}
+
+func TestTextAndBytesCoders(t *testing.T) {
+	tc := coder.NewTextCoder()
+	equal(t, "text/plain; charset=utf-8", tc.ContentType())
+
+	buf := new(bytes.Buffer)
+	equal(t, nil, tc.Encode(buf, "hello"))
+	var s string
+	equal(t, nil, tc.Decode(buf, &s))
+	equal(t, "hello", s)
+
+	equal(t, nil, tc.Encode(buf, time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)))
+	var tm time.Time
+	equal(t, nil, tc.Decode(buf, &tm))
+	equal(t, time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC), tm)
+
+	equal(t, true, tc.Encode(buf, 42) != nil)
+
+	bc := coder.NewBytesCoder()
+	equal(t, "application/octet-stream", bc.ContentType())
+
+	equal(t, nil, bc.Encode(buf, strings.NewReader("\x00\x01")))
+	var b []byte
+	equal(t, nil, bc.Decode(buf, &b))
+	equal(t, []byte{0, 1}, b)
+}
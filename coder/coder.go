@@ -60,6 +60,7 @@ func NewDecoder(unmarshal func(data []byte, v any) error) Decoder {
 }
 
 // Decode reads the next encoded value from its input and stores it in the value pointed to by v.
+// Errors reading the input, e.g. errors.ErrBodyTooLarge from middleware.MaxBytes, are returned as is.
 // It will panic if decoder function not set.
 func (d *decoder) Decode(r io.Reader, v any) error {
 	p, err := io.ReadAll(r)
@@ -82,6 +83,26 @@ func (d *decoder) Decode(r io.Reader, v any) error {
 	return nil
 }
 
+// A MediaTypeEncoder is an Encoder whose content type depends on the encoded value,
+// e.g. multipart/form-data with its boundary. Clients send the returned content type instead of ContentType.
+type MediaTypeEncoder interface {
+	EncodeMediaType(w io.Writer, v any) (string, error)
+}
+
+// A MediaTypeDecoder is a Decoder that needs the parameters of the content type, e.g. multipart/form-data
+// with its boundary. Servers pass the Content-Type of the request to DecodeMediaType instead of calling Decode.
+type MediaTypeDecoder interface {
+	DecodeMediaType(r io.Reader, contentType string, v any) error
+}
+
+// DecodeMediaType decodes with the content type if d is a MediaTypeDecoder, otherwise it calls d.Decode.
+func DecodeMediaType(d Decoder, r io.Reader, contentType string, v any) error {
+	if m, ok := d.(MediaTypeDecoder); ok {
+		return m.DecodeMediaType(r, contentType, v)
+	}
+	return d.Decode(r, v)
+}
+
 // A Coder is a pair of Encoder and Decoder.
 type Coder interface {
 	ContentType() string
@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
+	"net/http"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/gromey/proto-rest/coder"
+	protoerrors "github.com/gromey/proto-rest/errors"
 	"github.com/gromey/proto-rest/logger"
 )
 
@@ -91,3 +96,15 @@ func TestDecoder_Decode(t *testing.T) {
 		})
 	}
 }
+
+func TestDecoder_DecodeBodyTooLarge(t *testing.T) {
+	decoder := coder.NewDecoder(json.Unmarshal)
+
+	body := io.MultiReader(strings.NewReader("{\"field\""), iotest.ErrReader(protoerrors.ErrBodyTooLarge))
+
+	err := decoder.Decode(body, new(exampleStruct))
+
+	e, ok := err.(protoerrors.Error)
+	equal(t, true, ok)
+	equal(t, http.StatusRequestEntityTooLarge, e.Code())
+}
@@ -0,0 +1,243 @@
+package coder
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// formTag is the struct tag that names form fields: `form:"name,omitempty"`. Untagged fields use the field name,
+// "-" skips the field. Fields of nested structs are named parent.child, embedded structs are flattened.
+const formTag = "form"
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+type formField struct {
+	name      string
+	omitEmpty bool
+	value     reflect.Value
+}
+
+// formFields returns the leaf fields of the struct value rv. Nil pointers to nested structs are skipped
+// unless alloc reports that there are values under their name, then they are allocated.
+func formFields(rv reflect.Value, prefix string, alloc func(prefix string) bool) []formField {
+	var fields []formField
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+
+		tag := sf.Tag.Get(formTag)
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		fv := rv.Field(i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if isFormLeaf(ft) {
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			fields = append(fields, formField{name: prefix + name, omitEmpty: opts == "omitempty", value: fv})
+			continue
+		}
+
+		childPrefix := prefix
+		if !sf.Anonymous || name != "" {
+			if name == "" {
+				name = sf.Name
+			}
+			childPrefix = prefix + name + "."
+		}
+
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				if alloc == nil || !fv.CanSet() || !alloc(childPrefix) {
+					continue
+				}
+				fv.Set(reflect.New(ft))
+			}
+			fv = fv.Elem()
+		}
+
+		fields = append(fields, formFields(fv, childPrefix, alloc)...)
+	}
+
+	return fields
+}
+
+// isFormLeaf reports whether values of t are encoded as form values rather than nested fields.
+func isFormLeaf(t reflect.Type) bool {
+	return t.Kind() != reflect.Struct || t == fileType ||
+		t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// formValues formats the form values of fv. A nil pointer has no values.
+func formValues(fv reflect.Value) ([]string, error) {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		values := make([]string, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			s, err := formatFormValue(fv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+
+	if fv.Kind() == reflect.Ptr && fv.IsNil() {
+		return nil, nil
+	}
+
+	s, err := formatFormValue(fv)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+}
+
+func formatFormValue(fv reflect.Value) (string, error) {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return "", nil
+		}
+		fv = fv.Elem()
+	}
+
+	if m, ok := fv.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+	if fv.CanAddr() {
+		if m, ok := fv.Addr().Interface().(encoding.TextMarshaler); ok {
+			b, err := m.MarshalText()
+			return string(b), err
+		}
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(fv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(fv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(fv.Float(), 'g', -1, fv.Type().Bits()), nil
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(fv.Bytes()), nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported type %s", fv.Type())
+}
+
+// setFormValues sets fv from the form values. A slice takes all values, any other type the first one.
+func setFormValues(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		s := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := setFormValue(s.Index(i), v); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	return setFormValue(fv, values[0])
+}
+
+func setFormValue(fv reflect.Value, s string) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+
+	if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", fv.Type())
+		}
+		fv.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+
+	return nil
+}
+
+// structValue returns the struct v points to, or v itself when decoding is not required.
+func structValue(v any, settable bool) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if settable {
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return reflect.Value{}, fmt.Errorf("coder: decode requires a non-nil pointer to a struct, got %T", v)
+		}
+	}
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return reflect.Value{}, fmt.Errorf("coder: can't encode a nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("coder: a struct is required, got %T", v)
+	}
+	return rv, nil
+}
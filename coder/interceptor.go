@@ -0,0 +1,266 @@
+package coder
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// Sequencer chains Coder interceptors in a chain. The last interceptor is the outermost:
+// it encodes last and decodes first, like middleware.Sequencer.
+func Sequencer(baseCoder Coder, interceptors ...func(Coder) Coder) Coder {
+	for _, f := range interceptors {
+		baseCoder = f(baseCoder)
+	}
+	return baseCoder
+}
+
+// The EncodeFunc type is an adapter to allow the use of ordinary functions as Encoder.
+type EncodeFunc func(w io.Writer, v any) error
+
+// Encode calls f(w, v).
+func (f EncodeFunc) Encode(w io.Writer, v any) error {
+	return f(w, v)
+}
+
+// The DecodeFunc type is an adapter to allow the use of ordinary functions as Decoder.
+type DecodeFunc func(r io.Reader, v any) error
+
+// Decode calls f(r, v).
+func (f DecodeFunc) Decode(r io.Reader, v any) error {
+	return f(r, v)
+}
+
+// Wrap returns a Coder with the content type of c that encodes with enc and decodes with dec.
+// A nil enc or dec leaves the operation to c. Use it to write interceptors.
+// The result is a MediaTypeEncoder if enc is one, or if enc is nil and c is one.
+// It is always a MediaTypeDecoder, which passes the content type on to dec, or to c if dec is nil.
+func Wrap(c Coder, enc Encoder, dec Decoder) Coder {
+	w := &wrapper{Coder: c, enc: enc, dec: dec}
+
+	var m MediaTypeEncoder
+	var ok bool
+	if enc != nil {
+		m, ok = enc.(MediaTypeEncoder)
+	} else {
+		m, ok = c.(MediaTypeEncoder)
+	}
+	if ok {
+		return &mediaTypeWrapper{wrapper: w, m: m}
+	}
+	return w
+}
+
+type wrapper struct {
+	Coder
+	enc Encoder
+	dec Decoder
+}
+
+func (w *wrapper) Encode(wr io.Writer, v any) error {
+	if w.enc != nil {
+		return w.enc.Encode(wr, v)
+	}
+	return w.Coder.Encode(wr, v)
+}
+
+func (w *wrapper) Decode(r io.Reader, v any) error {
+	if w.dec != nil {
+		return w.dec.Decode(r, v)
+	}
+	return w.Coder.Decode(r, v)
+}
+
+func (w *wrapper) DecodeMediaType(r io.Reader, contentType string, v any) error {
+	if w.dec != nil {
+		return DecodeMediaType(w.dec, r, contentType, v)
+	}
+	return DecodeMediaType(w.Coder, r, contentType, v)
+}
+
+// mediaTypeDecodeFunc is the decoder of the interceptors, so they pass the content type on to the inner Coder.
+type mediaTypeDecodeFunc func(r io.Reader, contentType string, v any) error
+
+func (f mediaTypeDecodeFunc) Decode(r io.Reader, v any) error {
+	return f(r, "", v)
+}
+
+func (f mediaTypeDecodeFunc) DecodeMediaType(r io.Reader, contentType string, v any) error {
+	return f(r, contentType, v)
+}
+
+// mediaTypeEncodeFunc is the encoder of the interceptors around a MediaTypeEncoder, so they pass on the content type
+// the inner Coder returns.
+type mediaTypeEncodeFunc func(w io.Writer, v any) (string, error)
+
+func (f mediaTypeEncodeFunc) Encode(w io.Writer, v any) error {
+	_, err := f(w, v)
+	return err
+}
+
+func (f mediaTypeEncodeFunc) EncodeMediaType(w io.Writer, v any) (string, error) {
+	return f(w, v)
+}
+
+// interceptEncoder returns f as the Encoder of an interceptor around next. It is a MediaTypeEncoder if next is one.
+func interceptEncoder(next Coder, f func(w io.Writer, v any) (string, error)) Encoder {
+	if _, ok := next.(MediaTypeEncoder); ok {
+		return mediaTypeEncodeFunc(f)
+	}
+	return EncodeFunc(func(w io.Writer, v any) error {
+		_, err := f(w, v)
+		return err
+	})
+}
+
+// encodeMediaType encodes v with c and returns the content type of the encoded value.
+func encodeMediaType(c Coder, w io.Writer, v any) (string, error) {
+	if m, ok := c.(MediaTypeEncoder); ok {
+		return m.EncodeMediaType(w, v)
+	}
+	return c.ContentType(), c.Encode(w, v)
+}
+
+// mediaTypeWrapper is a wrapper that encodes with a content type that depends on the value, e.g. multipart/form-data.
+type mediaTypeWrapper struct {
+	*wrapper
+	m MediaTypeEncoder
+}
+
+func (w *mediaTypeWrapper) EncodeMediaType(wr io.Writer, v any) (string, error) {
+	return w.m.EncodeMediaType(wr, v)
+}
+
+// Validate validates values before they are encoded and after they are decoded.
+// If f is nil, values with a Validate() error method are validated with it.
+func Validate(f func(v any) error) func(Coder) Coder {
+	if f == nil {
+		f = func(v any) error {
+			if x, ok := v.(interface{ Validate() error }); ok {
+				return x.Validate()
+			}
+			return nil
+		}
+	}
+
+	return func(next Coder) Coder {
+		var enc Encoder = &validatingEncoder{next: next, f: f}
+		if m, ok := next.(MediaTypeEncoder); ok {
+			enc = &validatingMediaTypeEncoder{validatingEncoder: enc.(*validatingEncoder), m: m}
+		}
+		return Wrap(next, enc, mediaTypeDecodeFunc(func(r io.Reader, contentType string, v any) error {
+			if err := DecodeMediaType(next, r, contentType, v); err != nil {
+				return err
+			}
+			return f(v)
+		}))
+	}
+}
+
+type validatingEncoder struct {
+	next Coder
+	f    func(v any) error
+}
+
+func (e *validatingEncoder) Encode(w io.Writer, v any) error {
+	if err := e.f(v); err != nil {
+		return err
+	}
+	return e.next.Encode(w, v)
+}
+
+type validatingMediaTypeEncoder struct {
+	*validatingEncoder
+	m MediaTypeEncoder
+}
+
+func (e *validatingMediaTypeEncoder) EncodeMediaType(w io.Writer, v any) (string, error) {
+	if err := e.f(v); err != nil {
+		return "", err
+	}
+	return e.m.EncodeMediaType(w, v)
+}
+
+// Transform transforms the encoded bytes, e.g. to encrypt or sign them. encode is applied to
+// the output of the inner Coder, decode to the input before the inner Coder decodes it. Nil functions are skipped.
+// The content type of the inner Coder is kept, including one that depends on the value.
+func Transform(encode, decode func(p []byte) ([]byte, error)) func(Coder) Coder {
+	return func(next Coder) Coder {
+		var enc Encoder
+		if encode != nil {
+			enc = interceptEncoder(next, func(w io.Writer, v any) (string, error) {
+				buf := new(bytes.Buffer)
+				contentType, err := encodeMediaType(next, buf, v)
+				if err != nil {
+					return "", err
+				}
+				p, err := encode(buf.Bytes())
+				if err != nil {
+					return "", err
+				}
+				_, err = w.Write(p)
+				return contentType, err
+			})
+		}
+
+		var dec Decoder
+		if decode != nil {
+			dec = mediaTypeDecodeFunc(func(r io.Reader, contentType string, v any) error {
+				p, err := io.ReadAll(r)
+				if err != nil {
+					return err
+				}
+				if p, err = decode(p); err != nil {
+					return err
+				}
+				return DecodeMediaType(next, bytes.NewReader(p), contentType, v)
+			})
+		}
+
+		return Wrap(next, enc, dec)
+	}
+}
+
+// Envelope wraps values in an object with a single key, e.g. {"data": ...} with Envelope("data").
+// It works with coders that name fields by the json, xml, msgpack or form tag.
+func Envelope(key string) func(Coder) Coder {
+	tag := reflect.StructTag(fmt.Sprintf(`json:"%[1]s" xml:"%[1]s" msgpack:"%[1]s" form:"%[1]s"`, key))
+	var types sync.Map
+
+	// envelopeType returns struct{ XMLName; Data t } with the key in the tags of Data.
+	envelopeType := func(t reflect.Type) reflect.Type {
+		if et, ok := types.Load(t); ok {
+			return et.(reflect.Type)
+		}
+		et := reflect.StructOf([]reflect.StructField{
+			{Name: "XMLName", Type: reflect.TypeOf(xml.Name{}), Tag: `json:"-" xml:"envelope" msgpack:"-" form:"-"`},
+			{Name: "Data", Type: t, Tag: tag},
+		})
+		types.Store(t, et)
+		return et
+	}
+
+	return func(next Coder) Coder {
+		return Wrap(next, interceptEncoder(next, func(w io.Writer, v any) (string, error) {
+			rv := reflect.ValueOf(v)
+			if !rv.IsValid() {
+				return encodeMediaType(next, w, v)
+			}
+			env := reflect.New(envelopeType(rv.Type()))
+			env.Elem().Field(1).Set(rv)
+			return encodeMediaType(next, w, env.Interface())
+		}), mediaTypeDecodeFunc(func(r io.Reader, contentType string, v any) error {
+			rv := reflect.ValueOf(v)
+			if rv.Kind() != reflect.Ptr || rv.IsNil() {
+				return fmt.Errorf("coder: decode requires a non-nil pointer, got %T", v)
+			}
+			// The Data field points to v, so the inner Coder decodes into it.
+			env := reflect.New(envelopeType(rv.Type()))
+			env.Elem().Field(1).Set(rv)
+			return DecodeMediaType(next, r, contentType, env.Interface())
+		}))
+	}
+}
@@ -0,0 +1,118 @@
+package coder_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gromey/proto-rest/coder"
+)
+
+type order struct {
+	ID    string `json:"id" xml:"id" msgpack:"id"`
+	Total int    `json:"total" xml:"total" msgpack:"total"`
+}
+
+func (o *order) Validate() error {
+	if o.Total < 0 {
+		return errors.New("total must not be negative")
+	}
+	return nil
+}
+
+func TestEnvelope(t *testing.T) {
+	var tests = []struct {
+		name    string
+		coder   coder.Coder
+		encoded string
+	}{
+		{name: "json", coder: coder.NewJSONCoder(nil), encoded: `{"data":{"id":"a","total":2}}`},
+		{name: "xml", coder: coder.NewXMLCoder(), encoded: "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<envelope><data><id>a</id><total>2</total></data></envelope>"},
+		{name: "msgpack", coder: coder.NewMsgpackCoder(), encoded: "\x81\xa4data\x82\xa2id\xa1a\xa5total\x02"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := coder.Sequencer(test.coder, coder.Envelope("data"))
+			equal(t, test.coder.ContentType(), c.ContentType())
+
+			buf := new(bytes.Buffer)
+			equal(t, nil, c.Encode(buf, &order{ID: "a", Total: 2}))
+			equal(t, test.encoded, buf.String())
+
+			out := new(order)
+			equal(t, nil, c.Decode(buf, out))
+			equal(t, &order{ID: "a", Total: 2}, out)
+		})
+	}
+}
+
+func TestSequencer(t *testing.T) {
+	encode := func(p []byte) ([]byte, error) {
+		return []byte(base64.StdEncoding.EncodeToString(p)), nil
+	}
+	decode := func(p []byte) ([]byte, error) {
+		return base64.StdEncoding.DecodeString(string(p))
+	}
+
+	// Decoding runs from the last interceptor to the first: decode base64, unwrap the envelope, validate.
+	c := coder.Sequencer(coder.NewJSONCoder(nil), coder.Envelope("data"), coder.Validate(nil), coder.Transform(encode, decode))
+
+	buf := new(bytes.Buffer)
+	equal(t, nil, c.Encode(buf, &order{ID: "a", Total: 2}))
+	equal(t, base64.StdEncoding.EncodeToString([]byte(`{"data":{"id":"a","total":2}}`)), buf.String())
+
+	out := new(order)
+	equal(t, nil, c.Decode(buf, out))
+	equal(t, &order{ID: "a", Total: 2}, out)
+
+	err := c.Encode(buf, &order{Total: -1})
+	equal(t, "total must not be negative", err.Error())
+
+	in := base64.StdEncoding.EncodeToString([]byte(`{"data":{"id":"b","total":-1}}`))
+	err = c.Decode(strings.NewReader(in), new(order))
+	equal(t, "total must not be negative", err.Error())
+}
+
+func TestWrap_KeepsMediaTypeEncoder(t *testing.T) {
+	c := coder.Sequencer(coder.NewMultipartCoder(nil), coder.Validate(nil))
+	_, ok := c.(coder.MediaTypeEncoder)
+	equal(t, true, ok)
+
+	c = coder.Sequencer(coder.NewMultipartCoder(nil), coder.Transform(func(p []byte) ([]byte, error) { return p, nil }, nil))
+	_, ok = c.(coder.MediaTypeEncoder)
+	equal(t, true, ok)
+
+	c = coder.Sequencer(coder.NewMultipartCoder(nil), coder.Envelope("data"))
+	_, ok = c.(coder.MediaTypeEncoder)
+	equal(t, true, ok)
+
+	c = coder.Sequencer(coder.NewJSONCoder(nil), coder.Transform(func(p []byte) ([]byte, error) { return p, nil }, nil), coder.Envelope("data"))
+	_, ok = c.(coder.MediaTypeEncoder)
+	equal(t, false, ok)
+
+	c = coder.Wrap(coder.NewMultipartCoder(nil), nil, coder.DecodeFunc(func(r io.Reader, v any) error { return nil }))
+	_, ok = c.(coder.MediaTypeEncoder)
+	equal(t, true, ok)
+}
+
+func TestWrap_PassesContentType(t *testing.T) {
+	type form struct {
+		Text string `form:"text"`
+	}
+
+	identity := func(p []byte) ([]byte, error) { return p, nil }
+	c := coder.Sequencer(coder.NewMultipartCoder(nil), coder.Validate(nil), coder.Envelope("data"), coder.Transform(identity, identity))
+
+	buf := new(bytes.Buffer)
+	contentType, err := c.(coder.MediaTypeEncoder).EncodeMediaType(buf, &form{Text: "a"})
+	equal(t, nil, err)
+	equal(t, true, strings.HasPrefix(contentType, "multipart/form-data; boundary="))
+
+	out := new(form)
+	equal(t, nil, coder.DecodeMediaType(c, buf, contentType, out))
+	equal(t, "a", out.Text)
+}
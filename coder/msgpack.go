@@ -0,0 +1,795 @@
+package coder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MsgpackContentType is the content type of the MessagePack Coder.
+const MsgpackContentType = "application/msgpack"
+
+// NewMsgpackCoder returns a new Coder for MessagePack. Structs are encoded as maps with keys named
+// by the msgpack tag: `msgpack:"name,omitempty"`, untagged fields use the field name and "-" skips the field.
+// time.Time uses the timestamp extension. Values decoded into an interface{} are nil, bool, int64,
+// uint64 (above the int64 range), float64, string, []byte, []any, map[string]any or time.Time.
+func NewMsgpackCoder() Coder {
+	return NewCoder(MsgpackContentType, marshalMsgpack, unmarshalMsgpack)
+}
+
+const msgpackMaxDepth = 1000
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+
+	errMsgpackShort = errors.New("coder: msgpack: unexpected end of data")
+)
+
+func marshalMsgpack(v any) ([]byte, error) {
+	e := new(msgpackEncoder)
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func unmarshalMsgpack(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("coder: msgpack: decode requires a non-nil pointer, got %T", v)
+	}
+
+	d := &msgpackDecoder{data: data}
+	if err := d.decode(rv.Elem(), 0); err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return errors.New("coder: msgpack: invalid data after top-level value")
+	}
+	return nil
+}
+
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) encode(rv reflect.Value, depth int) error {
+	if depth > msgpackMaxDepth {
+		return errors.New("coder: msgpack: maximum nesting depth exceeded")
+	}
+
+	if !rv.IsValid() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+
+	if rv.Type() == timeType {
+		e.encodeTime(rv.Interface().(time.Time))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encode(rv.Elem(), depth+1)
+	case reflect.Bool:
+		if rv.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(rv.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, 0xca)
+		e.buf = appendUint32(e.buf, math.Float32bits(float32(rv.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = appendUint64(e.buf, math.Float64bits(rv.Float()))
+	case reflect.String:
+		e.encodeString(rv.String())
+	case reflect.Slice:
+		if rv.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBytes(rv.Bytes())
+			return nil
+		}
+		return e.encodeArray(rv, depth)
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			e.encodeBytes(b)
+			return nil
+		}
+		return e.encodeArray(rv, depth)
+	case reflect.Map:
+		if rv.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encodeMap(rv, depth)
+	case reflect.Struct:
+		return e.encodeStruct(rv, depth)
+	default:
+		return fmt.Errorf("coder: msgpack: unsupported type %s", rv.Type())
+	}
+
+	return nil
+}
+
+func (e *msgpackEncoder) encodeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.encodeUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = appendUint16(e.buf, uint16(n))
+	case n >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = appendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = appendUint64(e.buf, uint64(n))
+	}
+}
+
+func (e *msgpackEncoder) encodeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = appendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = appendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = appendUint64(e.buf, n)
+	}
+}
+
+func (e *msgpackEncoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgpackEncoder) encodeBytes(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *msgpackEncoder) encodeArrayHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xdc)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdd)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) encodeMapHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xde)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdf)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) encodeArray(rv reflect.Value, depth int) error {
+	e.encodeArrayHeader(rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		if err := e.encode(rv.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeMap(rv reflect.Value, depth int) error {
+	keys := rv.MapKeys()
+	// Sort string keys, so equal maps are encoded equally.
+	if rv.Type().Key().Kind() == reflect.String {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	}
+
+	e.encodeMapHeader(len(keys))
+	for _, k := range keys {
+		if err := e.encode(k, depth+1); err != nil {
+			return err
+		}
+		if err := e.encode(rv.MapIndex(k), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeStruct(rv reflect.Value, depth int) error {
+	fields := msgpackFields(rv.Type())
+
+	n := 0
+	for _, f := range fields {
+		if !f.omitEmpty || !rv.FieldByIndex(f.index).IsZero() {
+			n++
+		}
+	}
+
+	e.encodeMapHeader(n)
+	for _, f := range fields {
+		fv := rv.FieldByIndex(f.index)
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		e.encodeString(f.name)
+		if err := e.encode(fv, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTime encodes t with the timestamp extension in the smallest of its three formats.
+func (e *msgpackEncoder) encodeTime(t time.Time) {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case nsec == 0 && sec >= 0 && sec <= math.MaxUint32:
+		e.buf = append(e.buf, 0xd6, 0xff)
+		e.buf = appendUint32(e.buf, uint32(sec))
+	case sec >= 0 && sec>>34 == 0:
+		e.buf = append(e.buf, 0xd7, 0xff)
+		e.buf = appendUint64(e.buf, nsec<<34|uint64(sec))
+	default:
+		e.buf = append(e.buf, 0xc7, 12, 0xff)
+		e.buf = appendUint32(e.buf, uint32(nsec))
+		e.buf = appendUint64(e.buf, uint64(sec))
+	}
+}
+
+type msgpackField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// msgpackFields returns the encoded fields of the struct type t, with embedded structs flattened.
+func msgpackFields(t reflect.Type) []msgpackField {
+	var fields []msgpackField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("msgpack")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			for _, f := range msgpackFields(sf.Type) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, msgpackField{name: name, index: []int{i}, omitEmpty: opts == "omitempty"})
+	}
+	return fields
+}
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, errMsgpackShort
+	}
+	p := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return p, nil
+}
+
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	p, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(p[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(p)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(p)), nil
+	default:
+		return binary.BigEndian.Uint64(p), nil
+	}
+}
+
+// readLength reads the length of a str, bin, array or map, checking it against the remaining data.
+func (d *msgpackDecoder) readLength(size int, minElemSize int) (int, error) {
+	n, err := d.readUint(size)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.data)-d.pos)/uint64(minElemSize) {
+		return 0, errMsgpackShort
+	}
+	return int(n), nil
+}
+
+// msgpackValue is a decoded header: the kind of the value, its scalar value or its length.
+type msgpackValue struct {
+	kind  reflect.Kind // Bool, Int64, Uint64, Float64, String (str or bin), Slice (array), Map, Struct (ext), Invalid (nil)
+	bin   bool
+	b     bool
+	i     int64
+	u     uint64
+	f     float64
+	n     int
+	ext   int8
+	bytes []byte
+}
+
+func (d *msgpackDecoder) readValue() (msgpackValue, error) {
+	p, err := d.next(1)
+	if err != nil {
+		return msgpackValue{}, err
+	}
+	c := p[0]
+
+	switch {
+	case c <= 0x7f:
+		return msgpackValue{kind: reflect.Int64, i: int64(c)}, nil
+	case c >= 0xe0:
+		return msgpackValue{kind: reflect.Int64, i: int64(int8(c))}, nil
+	case c&0xf0 == 0x80:
+		return d.checkedLength(reflect.Map, int(c&0x0f))
+	case c&0xf0 == 0x90:
+		return d.checkedLength(reflect.Slice, int(c&0x0f))
+	case c&0xe0 == 0xa0:
+		return d.readBytes(int(c&0x1f), false)
+	}
+
+	switch c {
+	case 0xc0:
+		return msgpackValue{kind: reflect.Invalid}, nil
+	case 0xc2, 0xc3:
+		return msgpackValue{kind: reflect.Bool, b: c == 0xc3}, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb:
+		size := map[byte]int{0xc4: 1, 0xc5: 2, 0xc6: 4, 0xd9: 1, 0xda: 2, 0xdb: 4}[c]
+		n, err := d.readLength(size, 1)
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		return d.readBytes(n, c <= 0xc6)
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.readUint(1 << (c - 0xcc))
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		if u <= math.MaxInt64 {
+			return msgpackValue{kind: reflect.Int64, i: int64(u)}, nil
+		}
+		return msgpackValue{kind: reflect.Uint64, u: u}, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err := d.readUint(size)
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		// Sign-extend from the encoded size.
+		shift := 64 - 8*size
+		return msgpackValue{kind: reflect.Int64, i: int64(u<<shift) >> shift}, nil
+	case 0xca:
+		u, err := d.readUint(4)
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		return msgpackValue{kind: reflect.Float64, f: float64(math.Float32frombits(uint32(u)))}, nil
+	case 0xcb:
+		u, err := d.readUint(8)
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		return msgpackValue{kind: reflect.Float64, f: math.Float64frombits(u)}, nil
+	case 0xdc, 0xdd:
+		n, err := d.readLength(2<<(c-0xdc), 1)
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		return msgpackValue{kind: reflect.Slice, n: n}, nil
+	case 0xde, 0xdf:
+		n, err := d.readLength(2<<(c-0xde), 2)
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		return msgpackValue{kind: reflect.Map, n: n}, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.readExt(1 << (c - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		size := map[byte]int{0xc7: 1, 0xc8: 2, 0xc9: 4}[c]
+		n, err := d.readLength(size, 1)
+		if err != nil {
+			return msgpackValue{}, err
+		}
+		return d.readExt(n)
+	}
+
+	return msgpackValue{}, fmt.Errorf("coder: msgpack: invalid type code 0x%x", c)
+}
+
+func (d *msgpackDecoder) checkedLength(kind reflect.Kind, n int) (msgpackValue, error) {
+	minSize := 1
+	if kind == reflect.Map {
+		minSize = 2
+	}
+	if n*minSize > len(d.data)-d.pos {
+		return msgpackValue{}, errMsgpackShort
+	}
+	return msgpackValue{kind: kind, n: n}, nil
+}
+
+func (d *msgpackDecoder) readBytes(n int, bin bool) (msgpackValue, error) {
+	p, err := d.next(n)
+	if err != nil {
+		return msgpackValue{}, err
+	}
+	return msgpackValue{kind: reflect.String, bin: bin, bytes: p}, nil
+}
+
+func (d *msgpackDecoder) readExt(n int) (msgpackValue, error) {
+	t, err := d.next(1)
+	if err != nil {
+		return msgpackValue{}, err
+	}
+	p, err := d.next(n)
+	if err != nil {
+		return msgpackValue{}, err
+	}
+	return msgpackValue{kind: reflect.Struct, ext: int8(t[0]), bytes: p}, nil
+}
+
+// timeValue decodes the timestamp extension.
+func (v msgpackValue) timeValue() (time.Time, error) {
+	if v.kind != reflect.Struct || v.ext != -1 {
+		return time.Time{}, errors.New("coder: msgpack: expected a timestamp")
+	}
+	p := v.bytes
+	switch len(p) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(p)), 0).UTC(), nil
+	case 8:
+		u := binary.BigEndian.Uint64(p)
+		return time.Unix(int64(u&(1<<34-1)), int64(u>>34)).UTC(), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(p[4:])), int64(binary.BigEndian.Uint32(p))).UTC(), nil
+	}
+	return time.Time{}, errors.New("coder: msgpack: invalid timestamp")
+}
+
+func (d *msgpackDecoder) decode(rv reflect.Value, depth int) error {
+	if depth > msgpackMaxDepth {
+		return errors.New("coder: msgpack: maximum nesting depth exceeded")
+	}
+
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		x, err := d.decodeAny(depth)
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+		} else {
+			rv.Set(reflect.ValueOf(x))
+		}
+		return nil
+	}
+
+	v, err := d.readValue()
+	if err != nil {
+		return err
+	}
+	return d.decodeValue(rv, v, depth)
+}
+
+func (d *msgpackDecoder) decodeValue(rv reflect.Value, v msgpackValue, depth int) error {
+	if v.kind == reflect.Invalid {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return d.decodeValue(rv.Elem(), v, depth+1)
+	}
+
+	if rv.Type() == timeType {
+		t, err := v.timeValue()
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("coder: msgpack: can't decode %s into %s", v.kind, rv.Type())
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		x, err := d.anyValue(v, depth)
+		if err != nil {
+			return err
+		}
+		xv := reflect.ValueOf(x)
+		if !xv.Type().AssignableTo(rv.Type()) {
+			return mismatch()
+		}
+		rv.Set(xv)
+	case reflect.Bool:
+		if v.kind != reflect.Bool {
+			return mismatch()
+		}
+		rv.SetBool(v.b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.kind != reflect.Int64 || rv.OverflowInt(v.i) {
+			return mismatch()
+		}
+		rv.SetInt(v.i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.u
+		if v.kind == reflect.Int64 && v.i >= 0 {
+			u = uint64(v.i)
+		} else if v.kind != reflect.Uint64 {
+			return mismatch()
+		}
+		if rv.OverflowUint(u) {
+			return mismatch()
+		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		switch v.kind {
+		case reflect.Float64:
+			rv.SetFloat(v.f)
+		case reflect.Int64:
+			rv.SetFloat(float64(v.i))
+		case reflect.Uint64:
+			rv.SetFloat(float64(v.u))
+		default:
+			return mismatch()
+		}
+	case reflect.String:
+		if v.kind != reflect.String {
+			return mismatch()
+		}
+		rv.SetString(string(v.bytes))
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 && v.kind == reflect.String {
+			rv.SetBytes(append([]byte(nil), v.bytes...))
+			return nil
+		}
+		if v.kind != reflect.Slice {
+			return mismatch()
+		}
+		s := reflect.MakeSlice(rv.Type(), v.n, v.n)
+		for i := 0; i < v.n; i++ {
+			if err := d.decode(s.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+		rv.Set(s)
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 && v.kind == reflect.String {
+			if len(v.bytes) != rv.Len() {
+				return mismatch()
+			}
+			reflect.Copy(rv, reflect.ValueOf(v.bytes))
+			return nil
+		}
+		if v.kind != reflect.Slice || v.n != rv.Len() {
+			return mismatch()
+		}
+		for i := 0; i < v.n; i++ {
+			if err := d.decode(rv.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.kind != reflect.Map {
+			return mismatch()
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), v.n)
+		for i := 0; i < v.n; i++ {
+			k := reflect.New(rv.Type().Key()).Elem()
+			if err := d.decode(k, depth+1); err != nil {
+				return err
+			}
+			e := reflect.New(rv.Type().Elem()).Elem()
+			if err := d.decode(e, depth+1); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, e)
+		}
+		rv.Set(m)
+	case reflect.Struct:
+		if v.kind != reflect.Map {
+			return mismatch()
+		}
+		fields := msgpackFields(rv.Type())
+		for i := 0; i < v.n; i++ {
+			var name string
+			if err := d.decode(reflect.ValueOf(&name).Elem(), depth+1); err != nil {
+				return err
+			}
+			f := findMsgpackField(fields, name)
+			if f == nil {
+				// Unknown keys are skipped.
+				if _, err := d.decodeAny(depth + 1); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decode(rv.FieldByIndex(f.index), depth+1); err != nil {
+				return err
+			}
+		}
+	default:
+		return mismatch()
+	}
+
+	return nil
+}
+
+func findMsgpackField(fields []msgpackField, name string) *msgpackField {
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, name) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) decodeAny(depth int) (any, error) {
+	if depth > msgpackMaxDepth {
+		return nil, errors.New("coder: msgpack: maximum nesting depth exceeded")
+	}
+	v, err := d.readValue()
+	if err != nil {
+		return nil, err
+	}
+	return d.anyValue(v, depth)
+}
+
+func (d *msgpackDecoder) anyValue(v msgpackValue, depth int) (any, error) {
+	switch v.kind {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Bool:
+		return v.b, nil
+	case reflect.Int64:
+		return v.i, nil
+	case reflect.Uint64:
+		return v.u, nil
+	case reflect.Float64:
+		return v.f, nil
+	case reflect.String:
+		if v.bin {
+			return append([]byte(nil), v.bytes...), nil
+		}
+		return string(v.bytes), nil
+	case reflect.Slice:
+		s := make([]any, v.n)
+		for i := range s {
+			x, err := d.decodeAny(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = x
+		}
+		return s, nil
+	case reflect.Map:
+		m := make(map[string]any, v.n)
+		for i := 0; i < v.n; i++ {
+			k, err := d.decodeAny(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			e, err := d.decodeAny(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k := k.(type) {
+			case string:
+				m[k] = e
+			case []byte:
+				m[string(k)] = e
+			default:
+				m[fmt.Sprint(k)] = e
+			}
+		}
+		return m, nil
+	default:
+		if v.ext == -1 {
+			return v.timeValue()
+		}
+		return nil, fmt.Errorf("coder: msgpack: unsupported extension type %d", v.ext)
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
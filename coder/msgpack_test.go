@@ -0,0 +1,109 @@
+package coder_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/coder"
+)
+
+type msgpackItem struct {
+	ID       uint64            `msgpack:"id"`
+	Name     string            `msgpack:"name"`
+	Score    float64           `msgpack:"score"`
+	Delta    int32             `msgpack:"delta"`
+	Tags     []string          `msgpack:"tags"`
+	Raw      []byte            `msgpack:"raw"`
+	Attrs    map[string]int    `msgpack:"attrs"`
+	Created  time.Time         `msgpack:"created"`
+	Parent   *msgpackItem      `msgpack:"parent,omitempty"`
+	Extra    any               `msgpack:"extra"`
+	Labels   map[string]string `msgpack:"labels,omitempty"`
+	internal string
+}
+
+func TestMsgpackCoder_RoundTrip(t *testing.T) {
+	mc := coder.NewMsgpackCoder()
+	equal(t, "application/msgpack", mc.ContentType())
+
+	in := &msgpackItem{
+		ID:      math.MaxUint64,
+		Name:    "item",
+		Score:   1.5,
+		Delta:   -70000,
+		Tags:    []string{"a", "b"},
+		Raw:     []byte{0, 1, 2},
+		Attrs:   map[string]int{"x": 1, "y": -1},
+		Created: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Parent:  &msgpackItem{Name: "parent", Created: time.Unix(0, 0).UTC()},
+		Extra:   []any{"s", int64(-5), true, nil, map[string]any{"k": 1.25}},
+	}
+
+	buf := new(bytes.Buffer)
+	equal(t, nil, mc.Encode(buf, in))
+
+	out := new(msgpackItem)
+	equal(t, nil, mc.Decode(bytes.NewReader(buf.Bytes()), out))
+	equal(t, in, out)
+
+	var generic map[string]any
+	equal(t, nil, mc.Decode(bytes.NewReader(buf.Bytes()), &generic))
+	equal(t, "item", generic["name"])
+	equal(t, int64(-70000), generic["delta"])
+	equal(t, uint64(math.MaxUint64), generic["id"])
+	equal(t, []byte{0, 1, 2}, generic["raw"])
+}
+
+func TestMsgpackCoder_Format(t *testing.T) {
+	mc := coder.NewMsgpackCoder()
+
+	var tests = []struct {
+		name string
+		in   any
+		out  []byte
+	}{
+		{name: "nil", in: nil, out: []byte{0xc0}},
+		{name: "positive fixint", in: 7, out: []byte{0x07}},
+		{name: "negative fixint", in: -1, out: []byte{0xff}},
+		{name: "uint16", in: 300, out: []byte{0xcd, 0x01, 0x2c}},
+		{name: "int8", in: -100, out: []byte{0xd0, 0x9c}},
+		{name: "float32", in: float32(1.5), out: []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}},
+		{name: "fixstr", in: "abc", out: []byte{0xa3, 'a', 'b', 'c'}},
+		{name: "fixarray", in: []int{1, 2}, out: []byte{0x92, 0x01, 0x02}},
+		{name: "fixmap", in: map[string]bool{"a": true}, out: []byte{0x81, 0xa1, 'a', 0xc3}},
+		{name: "timestamp 32", in: time.Unix(1, 0), out: []byte{0xd6, 0xff, 0x00, 0x00, 0x00, 0x01}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			equal(t, nil, mc.Encode(buf, test.in))
+			equal(t, test.out, buf.Bytes())
+		})
+	}
+}
+
+func TestMsgpackCoder_DecodeErrors(t *testing.T) {
+	mc := coder.NewMsgpackCoder()
+
+	var tests = []struct {
+		name string
+		in   []byte
+		out  any
+	}{
+		{name: "truncated string", in: []byte{0xa3, 'a'}, out: new(string)},
+		{name: "huge array length", in: []byte{0xdd, 0xff, 0xff, 0xff, 0xff}, out: new([]int)},
+		{name: "type mismatch", in: []byte{0xa1, 'a'}, out: new(int)},
+		{name: "overflow", in: []byte{0xcd, 0x01, 0x2c}, out: new(int8)},
+		{name: "trailing data", in: []byte{0x01, 0x02}, out: new(int)},
+		{name: "not a pointer", in: []byte{0x01}, out: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			equal(t, true, mc.Decode(bytes.NewReader(test.in), test.out) != nil)
+		})
+	}
+}
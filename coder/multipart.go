@@ -0,0 +1,423 @@
+package coder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"reflect"
+	"strings"
+
+	protoerrors "github.com/gromey/proto-rest/errors"
+	"github.com/gromey/proto-rest/logger"
+)
+
+// MultipartContentType is the content type of the multipart Coder, without the boundary.
+const MultipartContentType = "multipart/form-data"
+
+var (
+	fileType      = reflect.TypeOf(File{})
+	filePtrType   = reflect.TypeOf((*File)(nil))
+	fileSliceType = reflect.TypeOf([]*File(nil))
+	fileFuncType  = reflect.TypeOf(FileFunc(nil))
+)
+
+// File represents a file part of a multipart form. Use *File or []*File fields.
+type File struct {
+	Filename    string    // The file name.
+	ContentType string    // The content type of the file. Defaults to application/octet-stream when encoding.
+	Size        int64     // The size of a decoded file, -1 for a file passed to a FileFunc.
+	Reader      io.Reader // The content to encode, or the content of a decoded file.
+
+	tmp *os.File
+}
+
+// Close removes the temporary file that holds the content of a large decoded file.
+func (f *File) Close() error {
+	if f.tmp == nil {
+		return nil
+	}
+	err := f.tmp.Close()
+	if rmErr := os.Remove(f.tmp.Name()); err == nil {
+		err = rmErr
+	}
+	f.tmp = nil
+	return err
+}
+
+// FileFunc is called by the decoder with each file part of its field while the body is read, so the file
+// is streamed instead of being kept in memory or in a temporary file. f.Reader reads the part and is only
+// valid until the function returns, reading more than MaxFileSize returns errors.ErrPartTooLarge.
+// Set the field before decoding, FileFunc fields are skipped when encoding.
+type FileFunc func(f *File) error
+
+// MultipartOptions represents options for configuring the multipart Coder.
+type MultipartOptions struct {
+	MaxFileSize  int64 // The maximum size of a file part. Defaults to 32 MiB.
+	MaxValueSize int64 // The maximum size of a value part. Defaults to 1 MiB.
+	MaxMemory    int64 // Files up to this size are kept in memory, larger ones in temporary files. Defaults to 1 MiB.
+	MaxParts     int   // The maximum number of parts. Defaults to 1000.
+}
+
+type multipartCoder struct {
+	opts MultipartOptions
+}
+
+// NewMultipartCoder returns a new Coder for multipart/form-data, which maps parts to struct fields
+// named by the form tag. Decoded files must be closed to remove their temporary files.
+// The Coder is a MediaTypeDecoder: the boundary is read from the content type, so Decode without it fails.
+func NewMultipartCoder(opts *MultipartOptions) Coder {
+	c := new(multipartCoder)
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.MaxFileSize <= 0 {
+		c.opts.MaxFileSize = 32 << 20
+	}
+	if c.opts.MaxValueSize <= 0 {
+		c.opts.MaxValueSize = 1 << 20
+	}
+	if c.opts.MaxMemory <= 0 {
+		c.opts.MaxMemory = 1 << 20
+	}
+	if c.opts.MaxParts <= 0 {
+		c.opts.MaxParts = 1000
+	}
+	return c
+}
+
+// ContentType returns multipart/form-data. Clients use the content type with the boundary returned by EncodeMediaType.
+func (c *multipartCoder) ContentType() string {
+	return MultipartContentType
+}
+
+// Encode encodes the struct pointed to by v as a multipart form with a generated boundary.
+func (c *multipartCoder) Encode(w io.Writer, v any) error {
+	_, err := c.EncodeMediaType(w, v)
+	return err
+}
+
+// EncodeMediaType encodes the struct pointed to by v and returns the content type with the boundary.
+func (c *multipartCoder) EncodeMediaType(w io.Writer, v any) (string, error) {
+	if logger.InLevel(logger.LevelDebug) {
+		logger.Debugf("Multipart encoder, input data: %#v", v)
+	}
+
+	rv, err := structValue(v, false)
+	if err != nil {
+		return "", err
+	}
+
+	mw := multipart.NewWriter(w)
+	for _, f := range formFields(rv, "", nil) {
+		switch x := f.value.Interface().(type) {
+		case FileFunc:
+			continue
+		case *File:
+			if x != nil {
+				err = writeFilePart(mw, f.name, x)
+			}
+		case []*File:
+			for _, file := range x {
+				// Nil files are skipped like a nil *File field.
+				if file == nil {
+					continue
+				}
+				if err = writeFilePart(mw, f.name, file); err != nil {
+					break
+				}
+			}
+		default:
+			if f.omitEmpty && f.value.IsZero() {
+				continue
+			}
+			var values []string
+			if values, err = formValues(f.value); err != nil {
+				return "", fmt.Errorf("coder: form field %q: %w", f.name, err)
+			}
+			for _, value := range values {
+				if err = mw.WriteField(f.name, value); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err = mw.Close(); err != nil {
+		return "", err
+	}
+
+	return mw.FormDataContentType(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func writeFilePart(mw *multipart.Writer, name string, f *File) error {
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(name), quoteEscaper.Replace(f.Filename)))
+	h.Set(ContentType, contentType)
+
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	if f.Reader != nil {
+		_, err = io.Copy(part, f.Reader)
+	}
+	return err
+}
+
+// Decode fails, since the boundary of the form is only known from the content type. Use DecodeMediaType.
+func (c *multipartCoder) Decode(r io.Reader, v any) error {
+	return c.DecodeMediaType(r, "", v)
+}
+
+// DecodeMediaType reads a multipart form with the boundary of contentType and stores its parts in the fields
+// of the struct pointed to by v. A content type other than multipart/form-data returns errors.ErrUnsupportedMediaType.
+// A part larger than its limit returns errors.ErrPartTooLarge. Parts without a matching field are discarded.
+func (c *multipartCoder) DecodeMediaType(r io.Reader, contentType string, v any) error {
+	rv, err := structValue(v, true)
+	if err != nil {
+		return err
+	}
+
+	boundary, err := multipartBoundary(contentType)
+	if err != nil {
+		return err
+	}
+
+	// The FileFunc fields set by the caller, they receive their files while the body is read.
+	funcs := make(map[string]FileFunc)
+	for _, f := range formFields(rv, "", nil) {
+		if fn, ok := f.value.Interface().(FileFunc); ok && fn != nil {
+			funcs[f.name] = fn
+		}
+	}
+
+	er := &errReader{r: r}
+
+	values := make(map[string][]string)
+	files := make(map[string][]*File)
+	defer func() {
+		// Close the files that were not stored, or all of them on error.
+		for _, fs := range files {
+			for _, f := range fs {
+				_ = f.Close()
+			}
+		}
+	}()
+
+	mr := multipart.NewReader(er, boundary)
+	for parts := 0; ; parts++ {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return er.bodyError(err)
+		}
+		if parts == c.opts.MaxParts {
+			return protoerrors.ErrBodyTooLarge
+		}
+
+		name := p.FormName()
+		if name == "" {
+			continue
+		}
+
+		if p.FileName() != "" {
+			if fn, ok := funcs[name]; ok {
+				f := &File{Filename: p.FileName(), ContentType: p.Header.Get(ContentType), Size: -1}
+				f.Reader = &limitedPart{r: p, remaining: c.opts.MaxFileSize}
+				if err = fn(f); err != nil {
+					return er.bodyError(err)
+				}
+				continue
+			}
+
+			f, err := c.readFile(p)
+			if err != nil {
+				return er.bodyError(err)
+			}
+			files[name] = append(files[name], f)
+			continue
+		}
+
+		var buf bytes.Buffer
+		n, err := io.Copy(&buf, io.LimitReader(p, c.opts.MaxValueSize+1))
+		if err != nil {
+			return er.bodyError(err)
+		}
+		if n > c.opts.MaxValueSize {
+			return protoerrors.ErrPartTooLarge
+		}
+		values[name] = append(values[name], buf.String())
+	}
+
+	hasPrefix := func(prefix string) bool {
+		for k := range values {
+			if strings.HasPrefix(k, prefix) {
+				return true
+			}
+		}
+		for k := range files {
+			if strings.HasPrefix(k, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	fields := formFields(rv, "", hasPrefix)
+
+	// Set the values first, so no file is stored in v when a value is invalid.
+	for _, f := range fields {
+		if t := f.value.Type(); t == filePtrType || t == fileSliceType || t == fileFuncType {
+			continue
+		}
+		if vs, ok := values[f.name]; ok {
+			if err = setFormValues(f.value, vs); err != nil {
+				return fmt.Errorf("coder: form field %q: %w", f.name, err)
+			}
+		}
+	}
+
+	for _, f := range fields {
+		switch f.value.Interface().(type) {
+		case *File:
+			if fs := files[f.name]; len(fs) != 0 {
+				f.value.Set(reflect.ValueOf(fs[0]))
+				files[f.name] = fs[1:]
+			}
+		case []*File:
+			if fs := files[f.name]; len(fs) != 0 {
+				f.value.Set(reflect.ValueOf(fs))
+				delete(files, f.name)
+			}
+		}
+	}
+
+	if logger.InLevel(logger.LevelDebug) {
+		logger.Debugf("Multipart decoder, output data: %#v", v)
+	}
+
+	return nil
+}
+
+// readFile reads a file part into memory, or into a temporary file when it is larger than MaxMemory.
+func (c *multipartCoder) readFile(p *multipart.Part) (*File, error) {
+	f := &File{Filename: p.FileName(), ContentType: p.Header.Get(ContentType)}
+	limited := io.LimitReader(p, c.opts.MaxFileSize+1)
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(limited, c.opts.MaxMemory+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > c.opts.MaxFileSize {
+		return nil, protoerrors.ErrPartTooLarge
+	}
+	if n <= c.opts.MaxMemory {
+		f.Size = n
+		f.Reader = bytes.NewReader(buf.Bytes())
+		return f, nil
+	}
+
+	tmp, err := os.CreateTemp("", "multipart-")
+	if err != nil {
+		return nil, err
+	}
+	f.tmp = tmp
+
+	if n, err = io.Copy(tmp, io.MultiReader(&buf, limited)); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if n > c.opts.MaxFileSize {
+		_ = f.Close()
+		return nil, protoerrors.ErrPartTooLarge
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	f.Size = n
+	f.Reader = tmp
+	return f, nil
+}
+
+// multipartBoundary returns the boundary parameter of a multipart/form-data content type.
+func multipartBoundary(contentType string) (string, error) {
+	if contentType == "" {
+		return "", errors.New("coder: multipart decoding requires the content type with the boundary")
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != MultipartContentType {
+		return "", protoerrors.ErrUnsupportedMediaType
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return "", errors.New("coder: multipart content type has no boundary")
+	}
+	return boundary, nil
+}
+
+// limitedPart reads a file part passed to a FileFunc and fails with errors.ErrPartTooLarge after remaining bytes.
+type limitedPart struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (p *limitedPart) Read(b []byte) (int, error) {
+	if p.remaining <= 0 {
+		// Probe for one more byte to tell an exact fit from an overflow.
+		var probe [1]byte
+		n, err := p.r.Read(probe[:])
+		if n > 0 {
+			return 0, protoerrors.ErrPartTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(b)) > p.remaining {
+		b = b[:p.remaining]
+	}
+	n, err := p.r.Read(b)
+	p.remaining -= int64(n)
+	return n, err
+}
+
+// errReader remembers the first error reading r other than io.EOF, e.g. errors.ErrBodyTooLarge,
+// since mime/multipart reports it with its message only.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// bodyError returns the error reading the body if there was one, otherwise err.
+func (r *errReader) bodyError(err error) error {
+	if r.err != nil {
+		return r.err
+	}
+	return err
+}
@@ -0,0 +1,277 @@
+package coder_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gromey/proto-rest/coder"
+	protoerrors "github.com/gromey/proto-rest/errors"
+)
+
+type upload struct {
+	Title   string   `form:"title"`
+	Count   int      `form:"count"`
+	Tags    []string `form:"tag"`
+	Note    string   `form:"note,omitempty"`
+	Owner   *owner   `form:"owner"`
+	Avatar  *coder.File
+	Files   []*coder.File `form:"files"`
+	Ignored string        `form:"-"`
+}
+
+type owner struct {
+	Name string `form:"name"`
+}
+
+func TestMultipartCoder(t *testing.T) {
+	mc := coder.NewMultipartCoder(&coder.MultipartOptions{MaxMemory: 4})
+
+	in := &upload{
+		Title:   "report",
+		Count:   2,
+		Tags:    []string{"a", "b"},
+		Owner:   &owner{Name: "bob"},
+		Avatar:  &coder.File{Filename: "a.png", ContentType: "image/png", Reader: strings.NewReader("png")},
+		Files:   []*coder.File{{Filename: "1.txt", Reader: strings.NewReader("first file")}, nil, {Filename: "2.txt", Reader: strings.NewReader("2")}},
+		Ignored: "x",
+	}
+
+	buf := new(bytes.Buffer)
+	contentType, err := mc.(coder.MediaTypeEncoder).EncodeMediaType(buf, in)
+	equal(t, nil, err)
+	equal(t, true, strings.HasPrefix(contentType, "multipart/form-data; boundary="))
+
+	// The body is readable by net/http.
+	req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(buf.Bytes()))
+	equal(t, nil, err)
+	req.Header.Set("Content-Type", contentType)
+	equal(t, nil, req.ParseMultipartForm(1<<20))
+	equal(t, []string{"a", "b"}, req.MultipartForm.Value["tag"])
+	equal(t, "bob", req.MultipartForm.Value["owner.name"][0])
+	equal(t, "a.png", req.MultipartForm.File["Avatar"][0].Filename)
+	equal(t, 0, len(req.MultipartForm.Value["note"]))
+
+	out := new(upload)
+	equal(t, nil, mc.(coder.MediaTypeDecoder).DecodeMediaType(bytes.NewReader(buf.Bytes()), contentType, out))
+	equal(t, "report", out.Title)
+	equal(t, 2, out.Count)
+	equal(t, []string{"a", "b"}, out.Tags)
+	equal(t, &owner{Name: "bob"}, out.Owner)
+	equal(t, "", out.Ignored)
+
+	equal(t, "a.png", out.Avatar.Filename)
+	equal(t, "image/png", out.Avatar.ContentType)
+	b, _ := io.ReadAll(out.Avatar.Reader)
+	equal(t, "png", string(b))
+
+	// The nil file is skipped.
+	equal(t, 2, len(out.Files))
+	equal(t, "2.txt", out.Files[1].Filename)
+	equal(t, int64(10), out.Files[0].Size)
+	b, _ = io.ReadAll(out.Files[0].Reader)
+	equal(t, "first file", string(b))
+
+	// The file larger than MaxMemory is kept in a temporary file until it is closed.
+	name := out.Files[0].Reader.(*os.File).Name()
+	equal(t, nil, out.Files[0].Close())
+	_, err = os.Stat(name)
+	equal(t, true, os.IsNotExist(err))
+}
+
+func TestMultipartCoder_Limits(t *testing.T) {
+	type form struct {
+		Text string      `form:"text"`
+		File *coder.File `form:"file"`
+	}
+
+	var tests = []struct {
+		name  string
+		opts  *coder.MultipartOptions
+		in    *form
+		limit int // Cuts the body after limit bytes with errors.ErrBodyTooLarge, as middleware.MaxBytes does.
+		err   error
+	}{
+		{
+			name: "file within limits",
+			opts: &coder.MultipartOptions{MaxFileSize: 8, MaxMemory: 2},
+			in:   &form{File: &coder.File{Filename: "f", Reader: strings.NewReader("12345678")}},
+		},
+		{
+			name: "file too large",
+			opts: &coder.MultipartOptions{MaxFileSize: 8, MaxMemory: 2},
+			in:   &form{File: &coder.File{Filename: "f", Reader: strings.NewReader("123456789")}},
+			err:  protoerrors.ErrPartTooLarge,
+		},
+		{
+			name: "file too large in memory",
+			opts: &coder.MultipartOptions{MaxFileSize: 8, MaxMemory: 64},
+			in:   &form{File: &coder.File{Filename: "f", Reader: strings.NewReader("123456789")}},
+			err:  protoerrors.ErrPartTooLarge,
+		},
+		{
+			name: "value too large",
+			opts: &coder.MultipartOptions{MaxValueSize: 4},
+			in:   &form{Text: "12345"},
+			err:  protoerrors.ErrPartTooLarge,
+		},
+		{
+			name: "too many parts",
+			opts: &coder.MultipartOptions{MaxParts: 1},
+			in:   &form{Text: "1", File: &coder.File{Filename: "f"}},
+			err:  protoerrors.ErrBodyTooLarge,
+		},
+		{
+			name:  "body too large",
+			in:    &form{Text: "1", File: &coder.File{Filename: "f", Reader: strings.NewReader("12345678")}},
+			limit: 100,
+			err:   protoerrors.ErrBodyTooLarge,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mc := coder.NewMultipartCoder(test.opts)
+
+			buf := new(bytes.Buffer)
+			contentType, err := mc.(coder.MediaTypeEncoder).EncodeMediaType(buf, test.in)
+			equal(t, nil, err)
+
+			var body io.Reader = buf
+			if test.limit != 0 {
+				body = io.MultiReader(io.LimitReader(buf, int64(test.limit)), iotest.ErrReader(protoerrors.ErrBodyTooLarge))
+			}
+
+			out := new(form)
+			err = coder.DecodeMediaType(mc, body, contentType, out)
+			equal(t, test.err, err)
+			if out.File != nil {
+				_ = out.File.Close()
+			}
+		})
+	}
+}
+
+func TestMultipartCoder_ContentType(t *testing.T) {
+	mc := coder.NewMultipartCoder(nil)
+
+	buf := new(bytes.Buffer)
+	contentType, err := mc.(coder.MediaTypeEncoder).EncodeMediaType(buf, &struct {
+		Text string `form:"text"`
+	}{Text: "a"})
+	equal(t, nil, err)
+
+	var tests = []struct {
+		name        string
+		contentType string
+		expErr      bool
+		err         error
+	}{
+		{
+			name:        "boundary of the content type",
+			contentType: contentType,
+		},
+		{
+			name:        "other boundary",
+			contentType: "multipart/form-data; boundary=other",
+			expErr:      true,
+		},
+		{
+			name:        "no boundary",
+			contentType: "multipart/form-data",
+			expErr:      true,
+		},
+		{
+			name:   "no content type",
+			expErr: true,
+		},
+		{
+			name:        "other media type",
+			contentType: "application/json",
+			expErr:      true,
+			err:         protoerrors.ErrUnsupportedMediaType,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := new(struct {
+				Text string `form:"text"`
+			})
+			err := coder.DecodeMediaType(mc, bytes.NewReader(buf.Bytes()), test.contentType, out)
+			equal(t, test.expErr, err != nil)
+			if test.err != nil {
+				equal(t, test.err, err)
+			}
+			if !test.expErr {
+				equal(t, "a", out.Text)
+			}
+		})
+	}
+}
+
+func TestMultipartCoder_FileFunc(t *testing.T) {
+	type form struct {
+		Text  string         `form:"text"`
+		Files coder.FileFunc `form:"file"`
+	}
+
+	var tests = []struct {
+		name     string
+		files    []string
+		expFiles []string
+		err      error
+	}{
+		{
+			name:     "files are streamed",
+			files:    []string{"1234", "12345678"},
+			expFiles: []string{"1234", "12345678"},
+		},
+		{
+			name:     "file too large",
+			files:    []string{"123456789"},
+			expFiles: []string{},
+			err:      protoerrors.ErrPartTooLarge,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mc := coder.NewMultipartCoder(&coder.MultipartOptions{MaxFileSize: 8})
+
+			buf := new(bytes.Buffer)
+			mw := multipart.NewWriter(buf)
+			for i, file := range test.files {
+				w, err := mw.CreateFormFile("file", strconv.Itoa(i)+".txt")
+				equal(t, nil, err)
+				_, _ = io.WriteString(w, file)
+			}
+			equal(t, nil, mw.WriteField("text", "a"))
+			equal(t, nil, mw.Close())
+
+			files := []string{}
+			out := &form{Files: func(f *coder.File) error {
+				equal(t, int64(-1), f.Size)
+				p, err := io.ReadAll(f.Reader)
+				if err != nil {
+					return err
+				}
+				files = append(files, string(p))
+				return nil
+			}}
+
+			err := coder.DecodeMediaType(mc, buf, mw.FormDataContentType(), out)
+			equal(t, test.err, err)
+			equal(t, test.expFiles, files)
+			if test.err == nil {
+				equal(t, "a", out.Text)
+			}
+		})
+	}
+}
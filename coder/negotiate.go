@@ -0,0 +1,139 @@
+package coder
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Negotiator selects a Coder by the media types of a request, so one handler can serve several formats.
+type Negotiator struct {
+	coders []Coder
+}
+
+// NewNegotiator returns a new Negotiator. The first Coder is the default one.
+func NewNegotiator(coders ...Coder) *Negotiator {
+	return &Negotiator{coders: coders}
+}
+
+// Default returns the default Coder.
+func (n *Negotiator) Default() Coder {
+	if len(n.coders) == 0 {
+		return nil
+	}
+	return n.coders[0]
+}
+
+// ForContentType returns the Coder for a body of the content type. An empty content type selects the default Coder.
+func (n *Negotiator) ForContentType(contentType string) (Coder, bool) {
+	if contentType == "" {
+		return n.Default(), len(n.coders) != 0
+	}
+
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	for _, c := range n.coders {
+		if mediaType(c) == t {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// ForAccept returns the Coder for a response to the Accept header: the most acceptable one,
+// or the first of equally acceptable ones. An empty Accept selects the default Coder.
+func (n *Negotiator) ForAccept(accept string) (Coder, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return n.Default(), len(n.coders) != 0
+	}
+
+	ranges := parseAccept(accept)
+
+	var best Coder
+	var bestQ float64
+	for _, c := range n.coders {
+		if q := acceptQuality(ranges, mediaType(c)); q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best, best != nil
+}
+
+// Accept returns an Accept header value that lists the media types of the coders by preference.
+func (n *Negotiator) Accept() string {
+	types := make([]string, 0, len(n.coders))
+	for i, c := range n.coders {
+		t := mediaType(c)
+		if i > 0 {
+			// Prefer the earlier coders.
+			q := 1 - float64(i)/10
+			if q < 0.1 {
+				q = 0.1
+			}
+			t += ";q=" + strconv.FormatFloat(q, 'f', -1, 64)
+		}
+		types = append(types, t)
+	}
+	return strings.Join(types, ", ")
+}
+
+// mediaType returns the content type of the Coder without parameters.
+func mediaType(c Coder) string {
+	t, _, _ := strings.Cut(c.ContentType(), ";")
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(fields[0])), "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+					q = f
+				}
+			}
+		}
+
+		ranges = append(ranges, acceptRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality of the most specific range that matches the media type.
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
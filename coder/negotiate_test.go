@@ -0,0 +1,83 @@
+package coder_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/gromey/proto-rest/coder"
+)
+
+// gogoMessage mimics a message generated by protoc-gen-gogo.
+type gogoMessage struct {
+	Name string
+}
+
+func (m *gogoMessage) Reset()         { *m = gogoMessage{} }
+func (m *gogoMessage) String() string { return m.Name }
+func (*gogoMessage) ProtoMessage()    {}
+
+func (m *gogoMessage) Marshal() ([]byte, error) { return []byte(m.Name), nil }
+
+func (m *gogoMessage) Unmarshal(b []byte) error {
+	m.Name = string(b)
+	return nil
+}
+
+func TestProtobufCoder(t *testing.T) {
+	pc := coder.NewProtobufCoder(nil, nil)
+	equal(t, "application/x-protobuf", pc.ContentType())
+
+	buf := new(bytes.Buffer)
+	equal(t, nil, pc.Encode(buf, &gogoMessage{Name: "proto"}))
+
+	out := &gogoMessage{Name: "stale"}
+	equal(t, nil, pc.Decode(buf, out))
+	equal(t, "proto", out.Name)
+
+	equal(t, true, pc.Encode(buf, &exampleStruct{}) != nil)
+
+	pj := coder.NewProtoJSONCoder(func(m coder.ProtoMessage) ([]byte, error) {
+		return json.Marshal(map[string]string{"name": m.String()})
+	}, nil)
+	buf.Reset()
+	equal(t, nil, pj.Encode(buf, &gogoMessage{Name: "json"}))
+	equal(t, `{"name":"json"}`, buf.String())
+}
+
+func TestNegotiator(t *testing.T) {
+	jsonCoder := coder.NewJSONCoder(nil)
+	msgpackCoder := coder.NewMsgpackCoder()
+	protoCoder := coder.NewProtobufCoder(nil, nil)
+	n := coder.NewNegotiator(jsonCoder, msgpackCoder, protoCoder)
+
+	equal(t, "application/json, application/msgpack;q=0.9, application/x-protobuf;q=0.8", n.Accept())
+
+	var tests = []struct {
+		accept string
+		exp    coder.Coder
+	}{
+		{accept: "", exp: jsonCoder},
+		{accept: "*/*", exp: jsonCoder},
+		{accept: "application/msgpack", exp: msgpackCoder},
+		{accept: "application/json;q=0.5, application/x-protobuf", exp: protoCoder},
+		{accept: "application/*;q=0.2, application/msgpack;q=0.3", exp: msgpackCoder},
+		{accept: "*/*, application/json;q=0", exp: msgpackCoder},
+		{accept: "text/html", exp: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			c, ok := n.ForAccept(test.accept)
+			equal(t, test.exp != nil, ok)
+			equal(t, test.exp, c)
+		})
+	}
+
+	c, ok := n.ForContentType("Application/MsgPack")
+	equal(t, true, ok)
+	equal(t, msgpackCoder, c)
+
+	_, ok = n.ForContentType("text/plain; charset=utf-8")
+	equal(t, false, ok)
+}
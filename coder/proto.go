@@ -0,0 +1,81 @@
+package coder
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ProtobufContentType is the content type of the Protocol Buffers Coder.
+const ProtobufContentType = "application/x-protobuf"
+
+// ProtoMessage is implemented by the messages generated by protoc-gen-go and protoc-gen-gogo.
+type ProtoMessage interface {
+	Reset()
+	String() string
+	ProtoMessage()
+}
+
+// ProtoMarshal and ProtoUnmarshal adapt a protobuf runtime, e.g. for google.golang.org/protobuf:
+//
+//	marshal := func(m coder.ProtoMessage) ([]byte, error) { return proto.Marshal(m.(proto.Message)) }
+//	unmarshal := func(b []byte, m coder.ProtoMessage) error { return proto.Unmarshal(b, m.(proto.Message)) }
+type (
+	ProtoMarshal   func(m ProtoMessage) ([]byte, error)
+	ProtoUnmarshal func(b []byte, m ProtoMessage) error
+)
+
+// NewProtobufCoder returns a new Coder for application/x-protobuf that encodes and decodes ProtoMessage values.
+// If marshal or unmarshal is nil, the Marshal() ([]byte, error) and Unmarshal([]byte) error methods
+// of the message are used, as generated by gogo/protobuf.
+func NewProtobufCoder(marshal ProtoMarshal, unmarshal ProtoUnmarshal) Coder {
+	if marshal == nil {
+		marshal = func(m ProtoMessage) ([]byte, error) {
+			if x, ok := m.(interface{ Marshal() ([]byte, error) }); ok {
+				return x.Marshal()
+			}
+			return nil, fmt.Errorf("coder: %T has no Marshal method, set the marshal function", m)
+		}
+	}
+	if unmarshal == nil {
+		unmarshal = func(b []byte, m ProtoMessage) error {
+			if x, ok := m.(interface{ Unmarshal([]byte) error }); ok {
+				m.Reset()
+				return x.Unmarshal(b)
+			}
+			return fmt.Errorf("coder: %T has no Unmarshal method, set the unmarshal function", m)
+		}
+	}
+	return newProtoCoder(ProtobufContentType, marshal, unmarshal)
+}
+
+// NewProtoJSONCoder returns a new Coder for application/json that encodes and decodes ProtoMessage values
+// with the canonical protobuf JSON mapping, e.g. with the protojson package:
+//
+//	marshal := func(m coder.ProtoMessage) ([]byte, error) { return protojson.Marshal(m.(proto.Message)) }
+//
+// If marshal or unmarshal is nil, encoding/json is used, which only matches the mapping for simple messages.
+func NewProtoJSONCoder(marshal ProtoMarshal, unmarshal ProtoUnmarshal) Coder {
+	if marshal == nil {
+		marshal = func(m ProtoMessage) ([]byte, error) { return json.Marshal(m) }
+	}
+	if unmarshal == nil {
+		unmarshal = func(b []byte, m ProtoMessage) error { return json.Unmarshal(b, m) }
+	}
+	return newProtoCoder(JSONContentType, marshal, unmarshal)
+}
+
+func newProtoCoder(contentType string, marshal ProtoMarshal, unmarshal ProtoUnmarshal) Coder {
+	return NewCoder(contentType, func(v any) ([]byte, error) {
+		m, ok := v.(ProtoMessage)
+		if !ok {
+			return nil, fmt.Errorf("coder: %T is not a protobuf message", v)
+		}
+		return marshal(m)
+	}, func(data []byte, v any) error {
+		m, ok := v.(ProtoMessage)
+		if !ok {
+			return fmt.Errorf("coder: %T is not a protobuf message", v)
+		}
+		return unmarshal(data, m)
+	})
+}
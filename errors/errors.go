@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 // Error interface type represents an error condition with an error code, with the nil value representing no error.
 type Error interface {
@@ -28,7 +31,59 @@ func (e err) Error() string {
 	return e.msg
 }
 
+// ErrBodyTooLarge is returned when reading a request body that exceeds the configured limit.
+var ErrBodyTooLarge = New(http.StatusRequestEntityTooLarge, "request body too large")
+
+// ErrPartTooLarge is returned when decoding a multipart form with a part that exceeds the configured limit.
+var ErrPartTooLarge = New(http.StatusRequestEntityTooLarge, "multipart part too large")
+
+// ErrUnsupportedMediaType is returned when no Coder can decode the content type of a request body.
+var ErrUnsupportedMediaType = New(http.StatusUnsupportedMediaType, "unsupported media type")
+
+// ErrNotAcceptable is returned when no Coder can encode a response in a media type the Accept header allows.
+var ErrNotAcceptable = New(http.StatusNotAcceptable, "not acceptable")
+
 // ErrVarMissing returns new error: "the required variable $varName is missing".
 func ErrVarMissing(varName string) error {
 	return fmt.Errorf("the required variable $%s is missing", varName)
 }
+
+// Problem represents a problem details document (RFC 9457) describing an error in an HTTP API.
+// It implements Error, so it can be returned as an error and written with server.WriteResponse.
+type Problem struct {
+	Type     string `json:"type,omitempty" xml:"type,omitempty"`
+	Title    string `json:"title,omitempty" xml:"title,omitempty"`
+	Status   int    `json:"status,omitempty" xml:"status,omitempty"`
+	Detail   string `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string `json:"instance,omitempty" xml:"instance,omitempty"`
+}
+
+// NewProblem returns a new Problem with the status text of code as the title.
+func NewProblem(code int, detail string) *Problem {
+	return &Problem{Title: http.StatusText(code), Status: code, Detail: detail}
+}
+
+// AsProblem converts err to a Problem. An Error keeps its code and message, any other error becomes a 500.
+func AsProblem(err error) *Problem {
+	switch e := err.(type) {
+	case *Problem:
+		return e
+	case Error:
+		return NewProblem(e.Code(), e.Error())
+	default:
+		return NewProblem(http.StatusInternalServerError, "")
+	}
+}
+
+// Code returns the HTTP status code.
+func (p *Problem) Code() int {
+	return p.Status
+}
+
+// Error returns the detail, or the title if there is no detail.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
@@ -0,0 +1,241 @@
+package health
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gromey/proto-rest/server"
+)
+
+// Kind defines which probes run a check.
+type Kind uint8
+
+const (
+	Readiness Kind = iota // Run by /readyz and /healthz: the service can't serve requests if it fails.
+	Liveness              // Run by all probes: the service must be restarted if it fails.
+)
+
+// Status of a check or of the whole service.
+const (
+	StatusPass = "pass" // All checks passed.
+	StatusWarn = "warn" // Only non-critical checks failed.
+	StatusFail = "fail" // A critical check failed.
+)
+
+// Check represents a named health check.
+type Check struct {
+	Name     string                          // The unique name of the check.
+	Func     func(ctx context.Context) error // Reports the health of a component, nil means healthy.
+	Kind     Kind                            // Which probes run the check.
+	Timeout  time.Duration                   // How long the check may run. Defaults to 5 seconds.
+	Critical bool                            // A failing critical check fails the probe, any other check only warns.
+	CacheTTL time.Duration                   // How long the result is reused. Zero runs the check on every probe.
+}
+
+// CheckResult represents the outcome of a single check.
+type CheckResult struct {
+	Status   string    `json:"status" xml:"status"`
+	Error    string    `json:"error,omitempty" xml:"error,omitempty"`
+	Critical bool      `json:"critical" xml:"critical"`
+	Duration string    `json:"duration" xml:"duration"`
+	Time     time.Time `json:"time" xml:"time"`
+}
+
+// Report represents the aggregated status rendered by the probes.
+// Checks are included only when the request has the verbose query parameter.
+type Report struct {
+	Status string                  `json:"status" xml:"status"`
+	Checks map[string]*CheckResult `json:"checks,omitempty" xml:"-"` // Encoded by MarshalXML.
+}
+
+// MarshalXML encodes the report with its checks as <check name="..."> elements sorted by name.
+func (r Report) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type namedResult struct {
+		Name string `xml:"name,attr"`
+		*CheckResult
+	}
+	doc := struct {
+		Status string        `xml:"status"`
+		Checks []namedResult `xml:"checks>check,omitempty"`
+	}{Status: r.Status}
+
+	names := make([]string, 0, len(r.Checks))
+	for name := range r.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		doc.Checks = append(doc.Checks, namedResult{Name: name, CheckResult: r.Checks[name]})
+	}
+
+	return e.EncodeElement(doc, start)
+}
+
+// Health runs registered checks and serves them as probes.
+type Health struct {
+	srv   server.Server
+	ready func() bool
+
+	mu     sync.RWMutex
+	checks []*check
+}
+
+type check struct {
+	Check
+
+	mu     sync.Mutex
+	result *CheckResult
+}
+
+// New returns a new Health that renders reports with srv.
+// If ready is not nil, /readyz fails while it returns false, e.g. pass server.Runner.Ready.
+func New(srv server.Server, ready func() bool) *Health {
+	return &Health{srv: srv, ready: ready}
+}
+
+// Register adds a check. A check with the same name replaces the previous one.
+func (h *Health) Register(c Check) {
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, existing := range h.checks {
+		if existing.Name == c.Name {
+			h.checks[i] = &check{Check: c}
+			return
+		}
+	}
+	h.checks = append(h.checks, &check{Check: c})
+}
+
+// Mount registers the /livez, /readyz and /healthz handlers on mux.
+func (h *Health) Mount(mux *http.ServeMux) {
+	mux.Handle("/livez", h.Livez())
+	mux.Handle("/readyz", h.Readyz())
+	mux.Handle("/healthz", h.Healthz())
+}
+
+// Livez returns a handler that runs the liveness checks.
+func (h *Health) Livez() http.Handler {
+	return h.handler(func(c *check) bool { return c.Kind == Liveness }, false)
+}
+
+// Readyz returns a handler that runs all checks and fails while the service is not ready.
+func (h *Health) Readyz() http.Handler {
+	return h.handler(func(*check) bool { return true }, true)
+}
+
+// Healthz returns a handler that runs all checks.
+func (h *Health) Healthz() http.Handler {
+	return h.handler(func(*check) bool { return true }, false)
+}
+
+func (h *Health) handler(filter func(*check) bool, readiness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.run(r.Context(), filter)
+
+		if readiness && h.ready != nil && !h.ready() {
+			report.Status = StatusFail
+		}
+
+		if _, verbose := r.URL.Query()["verbose"]; !verbose {
+			report.Checks = nil
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+
+		statusCode := http.StatusOK
+		if report.Status == StatusFail {
+			statusCode = http.StatusServiceUnavailable
+		}
+		h.srv.WriteResponse(w, statusCode, report)
+	})
+}
+
+// run runs the checks accepted by filter concurrently and aggregates their results.
+func (h *Health) run(ctx context.Context, filter func(*check) bool) *Report {
+	h.mu.RLock()
+	var checks []*check
+	for _, c := range h.checks {
+		if filter(c) {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	results := make([]*CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusPass, Checks: make(map[string]*CheckResult, len(checks))}
+	for i, c := range checks {
+		report.Checks[c.Name] = results[i]
+		if results[i].Status == StatusFail {
+			if c.Critical {
+				report.Status = StatusFail
+			} else if report.Status == StatusPass {
+				report.Status = StatusWarn
+			}
+		}
+	}
+
+	return report
+}
+
+// run returns the cached result or runs the check with its timeout.
+// A result is not cached if ctx is done, e.g. because the client of the probe has gone away.
+func (c *check) run(ctx context.Context) *CheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.result != nil && c.CacheTTL > 0 && time.Since(c.result.Time) < c.CacheTTL {
+		return c.result
+	}
+
+	probeCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errChan := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				errChan <- fmt.Errorf("check panicked: %v", rec)
+			}
+		}()
+		errChan <- c.Func(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := &CheckResult{Status: StatusPass, Critical: c.Critical, Duration: time.Since(start).String(), Time: start}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	if probeCtx.Err() == nil {
+		c.result = result
+	}
+
+	return result
+}
@@ -0,0 +1,181 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/coder"
+	"github.com/gromey/proto-rest/health"
+	"github.com/gromey/proto-rest/logger"
+	"github.com/gromey/proto-rest/server"
+)
+
+func init() {
+	logger.SetLogger(logger.New(nil))
+}
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+var srvJSON = server.New(coder.NewCoder("application/json", json.Marshal, json.Unmarshal))
+
+func TestHealth(t *testing.T) {
+	ready := true
+	h := health.New(srvJSON, func() bool { return ready })
+
+	h.Register(health.Check{
+		Name: "process",
+		Kind: health.Liveness,
+		Func: func(ctx context.Context) error { return nil },
+	})
+	h.Register(health.Check{
+		Name: "cache",
+		Func: func(ctx context.Context) error { return errors.New("cache is down") },
+	})
+
+	database := errors.New("database is down")
+	var databaseErr error
+	h.Register(health.Check{
+		Name:     "database",
+		Critical: true,
+		Func:     func(ctx context.Context) error { return databaseErr },
+	})
+
+	mux := http.NewServeMux()
+	h.Mount(mux)
+
+	var tests = []struct {
+		name      string
+		url       string
+		ready     bool
+		dbErr     error
+		expStatus int
+		expReport *health.Report
+	}{
+		{
+			name:      "liveness",
+			url:       "/livez",
+			ready:     true,
+			dbErr:     database,
+			expStatus: http.StatusOK,
+			expReport: &health.Report{Status: health.StatusPass},
+		},
+		{
+			name:      "non-critical failure",
+			url:       "/readyz",
+			ready:     true,
+			expStatus: http.StatusOK,
+			expReport: &health.Report{Status: health.StatusWarn},
+		},
+		{
+			name:      "critical failure",
+			url:       "/healthz",
+			ready:     true,
+			dbErr:     database,
+			expStatus: http.StatusServiceUnavailable,
+			expReport: &health.Report{Status: health.StatusFail},
+		},
+		{
+			name:      "not ready",
+			url:       "/readyz",
+			expStatus: http.StatusServiceUnavailable,
+			expReport: &health.Report{Status: health.StatusFail},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ready, databaseErr = test.ready, test.dbErr
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.url, nil))
+
+			report := new(health.Report)
+			equal(t, nil, json.Unmarshal(w.Body.Bytes(), report))
+			equal(t, test.expStatus, w.Code)
+			equal(t, test.expReport, report)
+		})
+	}
+
+	t.Run("verbose", func(t *testing.T) {
+		ready, databaseErr = true, nil
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz?verbose", nil))
+
+		report := new(health.Report)
+		equal(t, nil, json.Unmarshal(w.Body.Bytes(), report))
+		equal(t, 3, len(report.Checks))
+		equal(t, "cache is down", report.Checks["cache"].Error)
+		equal(t, health.StatusPass, report.Checks["database"].Status)
+	})
+}
+
+func TestHealth_VerboseXML(t *testing.T) {
+	h := health.New(server.New(coder.NewXMLCoder()), nil)
+	h.Register(health.Check{Name: "database", Critical: true, Func: func(ctx context.Context) error { return nil }})
+	h.Register(health.Check{Name: "cache", Func: func(ctx context.Context) error { return errors.New("cache is down") }})
+
+	w := httptest.NewRecorder()
+	h.Healthz().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz?verbose", nil))
+
+	var report struct {
+		Status string `xml:"status"`
+		Checks []struct {
+			Name   string `xml:"name,attr"`
+			Status string `xml:"status"`
+			Error  string `xml:"error"`
+		} `xml:"checks>check"`
+	}
+	equal(t, nil, xml.Unmarshal(w.Body.Bytes(), &report))
+	equal(t, health.StatusWarn, report.Status)
+	equal(t, 2, len(report.Checks))
+	equal(t, "cache", report.Checks[0].Name)
+	equal(t, "cache is down", report.Checks[0].Error)
+	equal(t, "database", report.Checks[1].Name)
+	equal(t, health.StatusPass, report.Checks[1].Status)
+}
+
+func TestHealth_CanceledProbe(t *testing.T) {
+	h := health.New(srvJSON, nil)
+	var runs int32 // Runs on a live context.
+	h.Register(health.Check{
+		Name:     "database",
+		Critical: true,
+		CacheTTL: time.Minute,
+		Func: func(ctx context.Context) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			atomic.AddInt32(&runs, 1)
+			return nil
+		},
+	})
+
+	// The client of the first probe has gone away, its failure is not cached.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	h.Healthz().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil).WithContext(ctx))
+
+	w = httptest.NewRecorder()
+	h.Healthz().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	equal(t, http.StatusOK, w.Code)
+
+	// The result of a completed probe is cached.
+	w = httptest.NewRecorder()
+	h.Healthz().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	equal(t, http.StatusOK, w.Code)
+	equal(t, int32(1), atomic.LoadInt32(&runs))
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+type principalKey struct{}
+
+// principalHolder lets an outer middleware, such as Timer, see the principal set further down the chain.
+// It is safe for concurrent use, since handlers may set the principal from other goroutines.
+type principalHolder struct {
+	mu        sync.Mutex
+	principal string
+}
+
+func (h *principalHolder) set(principal string) {
+	h.mu.Lock()
+	h.principal = principal
+	h.mu.Unlock()
+}
+
+func (h *principalHolder) get() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.principal
+}
+
+type principalHolderKey struct{}
+
+// WithPrincipal returns a copy of ctx that carries the authenticated principal.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	if h, ok := ctx.Value(principalHolderKey{}).(*principalHolder); ok {
+		h.set(principal)
+	}
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Principal returns the authenticated principal stored in ctx by the authentication middlewares.
+func Principal(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
+
+// CredentialsVerifier checks a username and password and returns the principal they belong to.
+type CredentialsVerifier func(r *http.Request, username, password string) (principal string, ok bool)
+
+// KeyVerifier checks an API key and returns the principal it belongs to.
+type KeyVerifier func(r *http.Request, key string) (principal string, ok bool)
+
+// StaticCredentials returns a CredentialsVerifier for a fixed set of username/password pairs.
+// The username is used as the principal. Passwords are compared in constant time.
+func StaticCredentials(users map[string]string) CredentialsVerifier {
+	return func(_ *http.Request, username, password string) (string, bool) {
+		expected, ok := users[username]
+		if !secureCompare(password, expected) || !ok {
+			return "", false
+		}
+		return username, true
+	}
+}
+
+// StaticKeys returns a KeyVerifier for a fixed set of API keys mapped to their principals.
+// Every known key is compared in constant time, so the lookup does not leak which key matched.
+func StaticKeys(keys map[string]string) KeyVerifier {
+	return func(_ *http.Request, key string) (string, bool) {
+		var principal string
+		var found bool
+		for k, p := range keys {
+			if secureCompare(key, k) {
+				principal, found = p, true
+			}
+		}
+		return principal, found
+	}
+}
+
+// secureCompare compares the digests of a and b, so the comparison time depends on neither content nor length.
+func secureCompare(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// BasicAuthOptions represents options for configuring the BasicAuth middleware.
+type BasicAuthOptions struct {
+	Realm    string              // The realm sent in the WWW-Authenticate header. Defaults to "Restricted".
+	Verifier CredentialsVerifier // Checks the credentials. Required.
+}
+
+// BasicAuth authenticates requests with HTTP Basic authentication (RFC 7617).
+// On success, the principal returned by the verifier is stored in the request context.
+func BasicAuth(opts *BasicAuthOptions) func(http.Handler) http.Handler {
+	realm := opts.Realm
+	if realm == "" {
+		realm = "Restricted"
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if username, password, ok := r.BasicAuth(); ok {
+				if principal, ok := opts.Verifier(r, username, password); ok {
+					next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+					return
+				}
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
+
+// APIKeyOptions represents options for configuring the APIKey middleware.
+type APIKeyOptions struct {
+	Header   string      // The header that carries the key. Defaults to "X-API-Key" if Query is empty.
+	Query    string      // The query parameter that carries the key. The header takes precedence.
+	Verifier KeyVerifier // Checks the key. Required.
+}
+
+// APIKey authenticates requests with an API key passed in a header or a query parameter.
+// On success, the principal returned by the verifier is stored in the request context.
+func APIKey(opts *APIKeyOptions) func(http.Handler) http.Handler {
+	header := opts.Header
+	if header == "" && opts.Query == "" {
+		header = "X-API-Key"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var key string
+			if header != "" {
+				key = r.Header.Get(header)
+			}
+			if key == "" && opts.Query != "" {
+				key = r.URL.Query().Get(opts.Query)
+			}
+			if key != "" {
+				if principal, ok := opts.Verifier(r, key); ok {
+					next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+					return
+				}
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
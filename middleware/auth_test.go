@@ -0,0 +1,157 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gromey/proto-rest/logger"
+	"github.com/gromey/proto-rest/middleware"
+)
+
+func init() {
+	logger.SetLogger(logger.New(nil))
+}
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+func principalHandler(t *testing.T, expPrincipal string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := middleware.Principal(r.Context())
+		equal(t, true, ok)
+		equal(t, expPrincipal, principal)
+	})
+}
+
+func TestBasicAuth(t *testing.T) {
+	mw := middleware.BasicAuth(&middleware.BasicAuthOptions{
+		Realm:    "internal",
+		Verifier: middleware.StaticCredentials(map[string]string{"admin": "secret"}),
+	})
+
+	var tests = []struct {
+		name      string
+		username  string
+		password  string
+		noAuth    bool
+		expStatus int
+	}{
+		{
+			name:      "valid credentials",
+			username:  "admin",
+			password:  "secret",
+			expStatus: http.StatusOK,
+		},
+		{
+			name:      "wrong password",
+			username:  "admin",
+			password:  "wrong",
+			expStatus: http.StatusUnauthorized,
+		},
+		{
+			name:      "unknown user",
+			username:  "guest",
+			password:  "secret",
+			expStatus: http.StatusUnauthorized,
+		},
+		{
+			name:      "no credentials",
+			noAuth:    true,
+			expStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/path", nil)
+			if !test.noAuth {
+				r.SetBasicAuth(test.username, test.password)
+			}
+			w := httptest.NewRecorder()
+
+			mw(principalHandler(t, test.username)).ServeHTTP(w, r)
+
+			equal(t, test.expStatus, w.Code)
+			if test.expStatus == http.StatusUnauthorized {
+				equal(t, `Basic realm="internal", charset="UTF-8"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	mw := middleware.APIKey(&middleware.APIKeyOptions{
+		Header:   "X-API-Key",
+		Query:    "api_key",
+		Verifier: middleware.StaticKeys(map[string]string{"k1": "service-a"}),
+	})
+
+	var tests = []struct {
+		name      string
+		url       string
+		header    string
+		expStatus int
+	}{
+		{
+			name:      "key in header",
+			url:       "/path",
+			header:    "k1",
+			expStatus: http.StatusOK,
+		},
+		{
+			name:      "key in query",
+			url:       "/path?api_key=k1",
+			expStatus: http.StatusOK,
+		},
+		{
+			name:      "unknown key",
+			url:       "/path",
+			header:    "k2",
+			expStatus: http.StatusUnauthorized,
+		},
+		{
+			name:      "missing key",
+			url:       "/path",
+			expStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, test.url, nil)
+			if test.header != "" {
+				r.Header.Set("X-API-Key", test.header)
+			}
+			w := httptest.NewRecorder()
+
+			mw(principalHandler(t, "service-a")).ServeHTTP(w, r)
+
+			equal(t, test.expStatus, w.Code)
+		})
+	}
+}
+
+func TestTimer_PrincipalFromGoroutine(t *testing.T) {
+	logger.SetLogger(logger.New(&logger.Config{Level: logger.LevelError}))
+	defer logger.SetLogger(logger.New(nil))
+
+	done := make(chan struct{})
+	h := middleware.Timer(logger.LevelError)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The principal is set concurrently with the access log of Timer.
+		go func() {
+			defer close(done)
+			_ = middleware.WithPrincipal(r.Context(), "admin")
+		}()
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/path", nil))
+	<-done
+
+	equal(t, http.StatusOK, w.Code)
+}
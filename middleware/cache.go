@@ -0,0 +1,310 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gromey/proto-rest/utils"
+)
+
+// CacheOptions represents options for configuring the response Cache.
+type CacheOptions struct {
+	TTL          time.Duration // How long a response is served from the cache. Defaults to 1 minute.
+	MaxEntries   int           // The maximum number of cached responses. Zero means no limit.
+	MaxSize      int64         // The maximum total size of cached bodies in bytes. Zero means no limit.
+	MaxEntrySize int64         // Responses with larger bodies are not cached. Defaults to 1 MiB.
+	VaryHeaders  []string      // Request headers that are part of the cache key, e.g. Accept or Accept-Language.
+}
+
+// Cache caches full responses to GET requests in memory.
+// Keys are built from the request path, the sorted query, the method and the values of VaryHeaders,
+// so InvalidatePrefix("/users") drops every cached response under /users.
+// Concurrent misses for the same key are coalesced, so the handler runs once.
+// Only 200 responses without Set-Cookie or Cache-Control no-store and private are cached.
+type Cache struct {
+	opts *CacheOptions
+
+	mu       sync.Mutex
+	ll       *list.List
+	items    map[string]*list.Element
+	size     int64
+	inflight map[string]*cacheCall
+}
+
+type cacheEntry struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	created time.Time
+	expires time.Time
+}
+
+type cacheCall struct {
+	done  chan struct{}
+	entry *cacheEntry
+}
+
+// NewCache returns a new response Cache.
+func NewCache(opts *CacheOptions) *Cache {
+	if opts == nil {
+		opts = new(CacheOptions)
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	if opts.MaxEntrySize <= 0 {
+		opts.MaxEntrySize = 1 << 20
+	}
+	return &Cache{
+		opts:     opts,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		inflight: make(map[string]*cacheCall),
+	}
+}
+
+// Handler returns a middleware that serves responses from the cache.
+func (c *Cache) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := c.key(r)
+
+		for {
+			c.mu.Lock()
+			if entry := c.get(key); entry != nil {
+				c.mu.Unlock()
+				entry.serve(w, "HIT")
+				return
+			}
+
+			call, ok := c.inflight[key]
+			if !ok {
+				call = &cacheCall{done: make(chan struct{})}
+				c.inflight[key] = call
+				c.mu.Unlock()
+				c.execute(w, r, next, key, call)
+				return
+			}
+			c.mu.Unlock()
+
+			select {
+			case <-call.done:
+			case <-r.Context().Done():
+				return
+			}
+			if call.entry == nil {
+				// The response was not cacheable, so it can't be shared.
+				next.ServeHTTP(w, r)
+				return
+			}
+			// Serve the result of the call, or retry if it has already been evicted.
+			if call.entry.expires.After(time.Now()) {
+				call.entry.serve(w, "HIT")
+				return
+			}
+		}
+	})
+}
+
+// execute runs the handler, streams its response to w and caches it if possible.
+func (c *Cache) execute(w http.ResponseWriter, r *http.Request, next http.Handler, key string, call *cacheCall) {
+	rec := &cacheRecorder{ResponseWriter: w, limit: c.opts.MaxEntrySize}
+	var completed bool
+
+	// Only the headers set by the handler are cached, not the per-request ones of outer middlewares,
+	// such as request IDs or CSP nonces.
+	w.Header().Set(CacheStatusHeader, "MISS")
+	before := w.Header().Clone()
+
+	// Runs even if the handler panics, so the waiters are released.
+	defer func() {
+		var entry *cacheEntry
+		if completed && rec.cacheable() {
+			now := time.Now()
+			entry = &cacheEntry{
+				key:     key,
+				status:  rec.status,
+				header:  handlerHeaders(before, rec.Header()),
+				body:    rec.buf.Bytes(),
+				created: now,
+				expires: now.Add(c.opts.TTL),
+			}
+		}
+
+		c.mu.Lock()
+		if entry != nil {
+			c.add(entry)
+		}
+		delete(c.inflight, key)
+		c.mu.Unlock()
+
+		call.entry = entry
+		close(call.done)
+	}()
+
+	next.ServeHTTP(rec, r)
+	completed = true
+}
+
+// InvalidatePrefix removes all cached responses whose key starts with prefix and returns their number.
+func (c *Cache) InvalidatePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(el)
+			n++
+		}
+	}
+	return n
+}
+
+// Purge removes all cached responses.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.size = 0
+}
+
+func (c *Cache) key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.URL.Path)
+
+	query := r.URL.Query()
+	if len(query) > 0 {
+		for _, values := range query {
+			sort.Strings(values)
+		}
+		b.WriteByte('?')
+		b.WriteString(query.Encode())
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(r.Method)
+
+	for _, name := range c.opts.VaryHeaders {
+		b.WriteByte(' ')
+		b.WriteString(strconv.Quote(strings.Join(r.Header.Values(name), ",")))
+	}
+
+	return b.String()
+}
+
+// get returns a fresh entry for key. The caller must hold the lock.
+func (c *Cache) get(key string) *cacheEntry {
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	entry := el.Value.(*cacheEntry)
+	if !entry.expires.After(time.Now()) {
+		c.remove(el)
+		return nil
+	}
+	c.ll.MoveToFront(el)
+	return entry
+}
+
+// add stores the entry, evicting the least recently used entries to fit the limits. The caller must hold the lock.
+func (c *Cache) add(entry *cacheEntry) {
+	if el, ok := c.items[entry.key]; ok {
+		c.remove(el)
+	}
+	c.items[entry.key] = c.ll.PushFront(entry)
+	c.size += int64(len(entry.body))
+
+	for c.ll.Len() > 1 && ((c.opts.MaxEntries > 0 && c.ll.Len() > c.opts.MaxEntries) || (c.opts.MaxSize > 0 && c.size > c.opts.MaxSize)) {
+		c.remove(c.ll.Back())
+	}
+}
+
+// remove deletes the element. The caller must hold the lock.
+func (c *Cache) remove(el *list.Element) {
+	entry := el.Value.(*cacheEntry)
+	c.ll.Remove(el)
+	delete(c.items, entry.key)
+	c.size -= int64(len(entry.body))
+}
+
+func (e *cacheEntry) serve(w http.ResponseWriter, status string) {
+	h := w.Header()
+	for name, values := range e.header {
+		h[name] = values
+	}
+	h.Set(CacheStatusHeader, status)
+	h.Set("Age", strconv.FormatInt(int64(time.Since(e.created)/time.Second), 10))
+	h.Set("Content-Length", strconv.Itoa(len(e.body)))
+	w.WriteHeader(e.status)
+	_, _ = w.Write(e.body)
+}
+
+// CacheStatusHeader is set on responses passed through the Cache middleware to HIT or MISS.
+const CacheStatusHeader = "X-Cache"
+
+// cacheRecorder passes the response through and keeps a copy of it.
+type cacheRecorder struct {
+	http.ResponseWriter
+	limit    int64
+	status   int
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (rec *cacheRecorder) WriteHeader(statusCode int) {
+	if rec.status == 0 {
+		rec.status = statusCode
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *cacheRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if !rec.overflow {
+		if int64(rec.buf.Len()+len(p)) > rec.limit {
+			rec.overflow = true
+			rec.buf = bytes.Buffer{}
+		} else {
+			rec.buf.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Flush flushes the underlying writer.
+func (rec *cacheRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter.
+func (rec *cacheRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *cacheRecorder) cacheable() bool {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if rec.overflow || rec.status != http.StatusOK || rec.Header().Get("Set-Cookie") != "" {
+		return false
+	}
+	cc := utils.ParseCacheControl(rec.Header().Values("Cache-Control"))
+	return !cc.Has("no-store") && !cc.Has("private")
+}
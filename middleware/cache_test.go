@@ -0,0 +1,181 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/middleware"
+)
+
+func TestCache(t *testing.T) {
+	var calls int32
+	cache := middleware.NewCache(&middleware.CacheOptions{TTL: time.Minute})
+	h := cache.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		_, _ = io.WriteString(w, "body")
+	}))
+
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		equal(t, http.StatusOK, w.Code)
+		equal(t, "body", w.Body.String())
+		return w
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request("/users?b=2&a=1")
+		}()
+	}
+	wg.Wait()
+	equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	equal(t, "HIT", request("/users?a=1&b=2").Header().Get(middleware.CacheStatusHeader))
+	equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	equal(t, 1, cache.InvalidatePrefix("/users"))
+
+	equal(t, "MISS", request("/users?a=1&b=2").Header().Get(middleware.CacheStatusHeader))
+	equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCache_Options(t *testing.T) {
+	type request struct {
+		path      string
+		header    string // The value of the Accept header.
+		expStatus string
+	}
+
+	var tests = []struct {
+		name     string
+		opts     *middleware.CacheOptions
+		sleep    time.Duration // Before the last request.
+		requests []request
+	}{
+		{
+			name:  "expired entry",
+			opts:  &middleware.CacheOptions{TTL: 20 * time.Millisecond},
+			sleep: 40 * time.Millisecond,
+			requests: []request{
+				{path: "/a", expStatus: "MISS"},
+				{path: "/a", expStatus: "HIT"},
+				{path: "/a", expStatus: "MISS"},
+			},
+		},
+		{
+			name: "max entries",
+			opts: &middleware.CacheOptions{MaxEntries: 2},
+			requests: []request{
+				{path: "/a", expStatus: "MISS"},
+				{path: "/b", expStatus: "MISS"},
+				{path: "/a", expStatus: "HIT"},
+				{path: "/c", expStatus: "MISS"},
+				{path: "/a", expStatus: "HIT"},
+				{path: "/b", expStatus: "MISS"},
+			},
+		},
+		{
+			name: "max size",
+			opts: &middleware.CacheOptions{MaxSize: 8}, // Each body is 4 bytes.
+			requests: []request{
+				{path: "/a", expStatus: "MISS"},
+				{path: "/b", expStatus: "MISS"},
+				{path: "/c", expStatus: "MISS"},
+				{path: "/c", expStatus: "HIT"},
+				{path: "/a", expStatus: "MISS"},
+			},
+		},
+		{
+			name: "vary headers",
+			opts: &middleware.CacheOptions{VaryHeaders: []string{"Accept"}},
+			requests: []request{
+				{path: "/a", header: "application/json", expStatus: "MISS"},
+				{path: "/a", header: "application/xml", expStatus: "MISS"},
+				{path: "/a", header: "application/json", expStatus: "HIT"},
+			},
+		},
+		{
+			name: "response with a cookie",
+			requests: []request{
+				{path: "/cookie", expStatus: "MISS"},
+				{path: "/cookie", expStatus: "MISS"},
+			},
+		},
+		{
+			name: "response with no-store",
+			requests: []request{
+				{path: "/no-store", expStatus: "MISS"},
+				{path: "/no-store", expStatus: "MISS"},
+			},
+		},
+		{
+			name: "error response",
+			requests: []request{
+				{path: "/missing", expStatus: "MISS"},
+				{path: "/missing", expStatus: "MISS"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := middleware.NewCache(test.opts).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/cookie":
+					w.Header().Set("Set-Cookie", "session=1")
+				case "/no-store":
+					w.Header().Set("Cache-Control", "no-store")
+				case "/missing":
+					w.WriteHeader(http.StatusNotFound)
+				}
+				_, _ = io.WriteString(w, "body")
+			}))
+
+			for i, req := range test.requests {
+				if i == len(test.requests)-1 {
+					time.Sleep(test.sleep)
+				}
+				r := httptest.NewRequest(http.MethodGet, req.path, nil)
+				if req.header != "" {
+					r.Header.Set("Accept", req.header)
+				}
+				w := httptest.NewRecorder()
+
+				h.ServeHTTP(w, r)
+
+				equal(t, req.expStatus, w.Header().Get(middleware.CacheStatusHeader))
+				equal(t, "body", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCache_OuterHeaders(t *testing.T) {
+	cache := middleware.NewCache(nil)
+	h := cache.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "body")
+	}))
+
+	for i, id := range []string{"1", "2"} {
+		w := httptest.NewRecorder()
+		// Set by an outer middleware for each request.
+		w.Header().Set("X-Request-Id", id)
+
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+		equal(t, []string{"MISS", "HIT"}[i], w.Header().Get(middleware.CacheStatusHeader))
+		equal(t, id, w.Header().Get("X-Request-Id"))
+		equal(t, "text/plain", w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,323 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gromey/proto-rest/logger"
+)
+
+// A Compressor creates writers for one content coding, such as gzip or br.
+// If the returned writer has a Reset(io.Writer) method, it is pooled and reused.
+type Compressor interface {
+	Encoding() string
+	NewWriter(w io.Writer) io.WriteCloser
+}
+
+type gzipCompressor int
+
+// GzipCompressor returns a Compressor for the gzip content coding with the given compression level.
+func GzipCompressor(level int) Compressor {
+	return gzipCompressor(level)
+}
+
+// Encoding returns "gzip".
+func (c gzipCompressor) Encoding() string {
+	return "gzip"
+}
+
+// NewWriter returns a new gzip writer.
+func (c gzipCompressor) NewWriter(w io.Writer) io.WriteCloser {
+	zw, err := gzip.NewWriterLevel(w, int(c))
+	if err != nil {
+		zw = gzip.NewWriter(w)
+	}
+	return zw
+}
+
+type deflateCompressor int
+
+// DeflateCompressor returns a Compressor for the deflate content coding with the given compression level.
+func DeflateCompressor(level int) Compressor {
+	return deflateCompressor(level)
+}
+
+// Encoding returns "deflate".
+func (c deflateCompressor) Encoding() string {
+	return "deflate"
+}
+
+// NewWriter returns a new flate writer.
+func (c deflateCompressor) NewWriter(w io.Writer) io.WriteCloser {
+	zw, err := flate.NewWriter(w, int(c))
+	if err != nil {
+		zw, _ = flate.NewWriter(w, flate.DefaultCompression)
+	}
+	return zw
+}
+
+// CompressOptions represents options for configuring the Compress middleware.
+type CompressOptions struct {
+	// Compressors in order of server preference. Defaults to gzip and deflate with the default compression level.
+	// Add a brotli Compressor first to prefer it over gzip.
+	Compressors []Compressor
+	// Responses shorter than MinSize bytes are not compressed. Defaults to 1024.
+	MinSize int
+	// Media types that are compressed. A type ending with "/*" matches any subtype.
+	// Defaults to text/*, JSON, XML, JavaScript and their +json/+xml variants.
+	ContentTypes []string
+}
+
+var defaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/xml",
+	"application/javascript",
+	"application/x-javascript",
+	"application/x-ndjson",
+	"application/problem+json",
+	"application/problem+xml",
+	"image/svg+xml",
+}
+
+type compressPool struct {
+	Compressor
+	pool sync.Pool
+}
+
+func (p *compressPool) get(w io.Writer) io.WriteCloser {
+	if zw, ok := p.pool.Get().(io.WriteCloser); ok {
+		zw.(interface{ Reset(io.Writer) }).Reset(w)
+		return zw
+	}
+	return p.NewWriter(w)
+}
+
+func (p *compressPool) put(zw io.WriteCloser) {
+	if _, ok := zw.(interface{ Reset(io.Writer) }); ok {
+		p.pool.Put(zw)
+	}
+}
+
+// Compress compresses responses according to the Accept-Encoding request header.
+// Only responses of compressible content types that reach MinSize bytes, or are flushed, are compressed.
+func Compress(opts *CompressOptions) func(http.Handler) http.Handler {
+	if opts == nil {
+		opts = new(CompressOptions)
+	}
+
+	compressors := opts.Compressors
+	if len(compressors) == 0 {
+		compressors = []Compressor{GzipCompressor(gzip.DefaultCompression), DeflateCompressor(flate.DefaultCompression)}
+	}
+	pools := make([]*compressPool, len(compressors))
+	for i, c := range compressors {
+		pools[i] = &compressPool{Compressor: c}
+	}
+
+	minSize := opts.MinSize
+	if minSize <= 0 {
+		minSize = 1024
+	}
+
+	types := opts.ContentTypes
+	if len(types) == 0 {
+		types = defaultCompressibleTypes
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			pool := negotiateEncoding(r.Header.Get("Accept-Encoding"), pools)
+			if pool == nil || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, pool: pool, minSize: minSize, types: types, status: http.StatusOK}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the pool of the acceptable coding with the highest quality,
+// preferring the server order on ties.
+func negotiateEncoding(accept string, pools []*compressPool) *compressPool {
+	if accept == "" {
+		return nil
+	}
+
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	var best *compressPool
+	var bestQ float64
+	for _, p := range pools {
+		q, ok := qualities[p.Encoding()]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = p, q
+		}
+	}
+
+	return best
+}
+
+func isCompressible(contentType string, types []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range types {
+		if strings.HasSuffix(t, "/*") {
+			if strings.HasPrefix(mediaType, t[:len(t)-1]) {
+				return true
+			}
+			continue
+		}
+		if mediaType == t {
+			return true
+		}
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	pool    *compressPool
+	minSize int
+	types   []string
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	zw          io.WriteCloser
+}
+
+// WriteHeader defers writing the status code until it is known whether the response is compressed.
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+	if statusCode >= 100 && statusCode < 200 {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = statusCode
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		cw.decide(false)
+	}
+}
+
+// Write buffers the data until MinSize bytes are collected, then writes it compressed or as is.
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	cw.wroteHeader = true
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.zw != nil {
+		return cw.zw.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush writes the buffered data and flushes both the compressor and the underlying writer.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if err := cw.decide(len(cw.buf) > 0); err != nil {
+			return
+		}
+	}
+	if f, ok := cw.zw.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide commits the headers and writes the buffered data, compressing it if compress is true
+// and the response is eligible.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if compress && h.Get("Content-Encoding") == "" && isCompressible(h.Get("Content-Type"), cw.types) {
+		h.Set("Content-Encoding", cw.pool.Encoding())
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.zw = cw.pool.get(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.zw != nil {
+		_, err := cw.zw.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if !cw.wroteHeader {
+			return
+		}
+		_ = cw.decide(false)
+	}
+	if cw.zw != nil {
+		if err := cw.zw.Close(); err != nil {
+			if logger.InLevel(logger.LevelError) {
+				logger.Error("Can't close compressor. Error: ", err)
+			}
+		}
+		cw.pool.put(cw.zw)
+		cw.zw = nil
+	}
+}
@@ -0,0 +1,91 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gromey/proto-rest/middleware"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"field":"example"}`, 100)
+
+	var tests = []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		expEncoding    string
+	}{
+		{
+			name:           "large JSON body",
+			acceptEncoding: "gzip, deflate",
+			contentType:    "application/json",
+			body:           large,
+			expEncoding:    "gzip",
+		},
+		{
+			name:           "preferred by quality",
+			acceptEncoding: "gzip;q=0.5, deflate",
+			contentType:    "application/json",
+			body:           large,
+			expEncoding:    "deflate",
+		},
+		{
+			name:           "small body",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			body:           `{"field":"example"}`,
+		},
+		{
+			name:           "not compressible content type",
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+			body:           large,
+		},
+		{
+			name:        "no Accept-Encoding",
+			contentType: "application/json",
+			body:        large,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := middleware.Compress(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				w.Header().Set("Content-Length", "1")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = io.WriteString(w, test.body)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/path", nil)
+			if test.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			equal(t, http.StatusCreated, w.Code)
+			equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			equal(t, test.expEncoding, w.Header().Get("Content-Encoding"))
+
+			if test.expEncoding != "gzip" {
+				return
+			}
+
+			equal(t, "", w.Header().Get("Content-Length"))
+
+			zr, err := gzip.NewReader(w.Body)
+			equal(t, nil, err)
+			b, err := io.ReadAll(zr)
+			equal(t, nil, err)
+			equal(t, test.body, string(b))
+		})
+	}
+}
@@ -2,46 +2,237 @@ package middleware
 
 import (
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
 // CORSOptions represents a functional option for configuring the CORS middleware.
 type CORSOptions struct {
-	AllowedOrigins   []string // The origins that the server allows.
-	AllowMethods     []string // List of methods that the server allows.
-	AllowHeaders     []string // List of headers that the server allows.
-	MaxAge           int      // Tells the browser how long (in seconds) to cache the response to the preflight request.
-	AllowCredentials bool     // Allow browsers to expose the response to the external JavaScript code.
+	// The origins that the server allows. "*" allows any origin,
+	// a single "*" in a subdomain position (e.g. "https://*.example.com") allows any subdomain.
+	AllowedOrigins []string
+	// The origins that the server allows, as regular expressions matched against the whole origin.
+	AllowedOriginPatterns []*regexp.Regexp
+	// A custom origin check. If set, it is consulted when the origin is not allowed by the lists above.
+	OriginValidator func(r *http.Request, origin string) bool
+	// List of methods that the server allows. Defaults to GET, HEAD and POST.
+	AllowMethods []string
+	// List of headers that the server allows. "*" allows any header.
+	AllowHeaders []string
+	// List of response headers that browsers are allowed to expose to JavaScript code.
+	ExposeHeaders []string
+	// Tells the browser how long (in seconds) to cache the response to the preflight request.
+	// Zero omits the header, a negative value disables caching.
+	MaxAge int
+	// Allow browsers to expose the response to the external JavaScript code.
+	// Credentials are never allowed for origins matched only by "*".
+	AllowCredentials bool
+	// Allow requests from public networks to private network resources (Private Network Access).
+	AllowPrivateNetwork bool
+	// The status code of a successful preflight response. Defaults to 200 OK, some servers prefer 204 No Content.
+	OptionsSuccessStatus int
+	// Pass preflight requests to the next handler instead of responding to them.
+	OptionsPassthrough bool
+}
+
+type cors struct {
+	opts            *CORSOptions
+	allowAll        bool
+	origins         map[string]struct{}
+	wildcards       [][2]string
+	methods         map[string]struct{}
+	allowAllHeaders bool
+	headers         map[string]struct{}
+	allowMethods    string
+	allowHeaders    string
+	exposeHeaders   string
+	maxAge          string
+	status          int
 }
 
 // AllowCORS sets headers for CORS mechanism supports secure.
+// Preflight requests are OPTIONS requests carrying both Origin and Access-Control-Request-Method headers,
+// any other OPTIONS request is passed to next.
 func AllowCORS(next http.Handler, opts *CORSOptions) http.Handler {
+	c := newCORS(opts)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if origin := r.Header.Get("Origin"); checkOrigin(origin, opts.AllowedOrigins) {
-			w.Header().Set("Access-Control-Allow-Origin", origin)
-		}
-		if r.Method == http.MethodOptions {
-			w.Header().Set("Access-Control-Allow-Methods", strings.Join(opts.AllowMethods, ","))
-			w.Header().Set("Access-Control-Allow-Headers", strings.Join(opts.AllowHeaders, ","))
-			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(opts.MaxAge))
-			if opts.AllowCredentials {
-				w.Header().Set("Access-Control-Allow-Credentials", "true")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(w, r)
+			if opts.OptionsPassthrough {
+				next.ServeHTTP(w, r)
+				return
 			}
+			w.WriteHeader(c.status)
 			return
 		}
+		c.actual(w, r)
 		next.ServeHTTP(w, r)
 	})
 }
 
-func checkOrigin(origin string, allowedOrigins []string) bool {
+func newCORS(opts *CORSOptions) *cors {
+	c := &cors{
+		opts:    opts,
+		origins: make(map[string]struct{}),
+		methods: make(map[string]struct{}),
+		headers: make(map[string]struct{}),
+		status:  opts.OptionsSuccessStatus,
+	}
+
+	for _, origin := range opts.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			c.allowAll = true
+		case strings.Count(origin, "*") == 1:
+			i := strings.IndexByte(origin, '*')
+			c.wildcards = append(c.wildcards, [2]string{origin[:i], origin[i+1:]})
+		default:
+			c.origins[origin] = struct{}{}
+		}
+	}
+
+	methods := opts.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	for _, method := range methods {
+		c.methods[strings.ToUpper(method)] = struct{}{}
+	}
+	c.allowMethods = strings.Join(methods, ",")
+
+	for _, header := range opts.AllowHeaders {
+		if header == "*" {
+			c.allowAllHeaders = true
+			continue
+		}
+		c.headers[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+	c.allowHeaders = strings.Join(opts.AllowHeaders, ",")
+	c.exposeHeaders = strings.Join(opts.ExposeHeaders, ",")
+
+	switch {
+	case opts.MaxAge > 0:
+		c.maxAge = strconv.Itoa(opts.MaxAge)
+	case opts.MaxAge < 0:
+		c.maxAge = "0"
+	}
+
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+
+	return c
+}
+
+// checkOrigin reports whether the origin is allowed and whether it was matched explicitly rather than by "*".
+func (c *cors) checkOrigin(r *http.Request, origin string) (allowed, explicit bool) {
 	if origin == "" {
-		return false
+		return false, false
+	}
+	lower := strings.ToLower(origin)
+	if _, ok := c.origins[lower]; ok {
+		return true, true
+	}
+	for _, w := range c.wildcards {
+		if len(lower) > len(w[0])+len(w[1]) && strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) {
+			return true, true
+		}
+	}
+	for _, re := range c.opts.AllowedOriginPatterns {
+		if re.MatchString(origin) {
+			return true, true
+		}
+	}
+	if c.opts.OriginValidator != nil && c.opts.OriginValidator(r, origin) {
+		return true, true
+	}
+	return c.allowAll, false
+}
+
+func (c *cors) setOrigin(h http.Header, origin string, explicit bool) {
+	if !explicit {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if c.opts.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	if c.opts.AllowPrivateNetwork {
+		h.Add("Vary", "Access-Control-Request-Private-Network")
+	}
+
+	origin := r.Header.Get("Origin")
+	allowed, explicit := c.checkOrigin(r, origin)
+	if !allowed {
+		return
+	}
+
+	if _, ok := c.methods[strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))]; !ok {
+		return
+	}
+
+	requested := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+	if !c.allowAllHeaders {
+		for _, header := range requested {
+			if _, ok := c.headers[header]; !ok {
+				return
+			}
+		}
+	}
+
+	c.setOrigin(h, origin, explicit)
+	h.Set("Access-Control-Allow-Methods", c.allowMethods)
+	if len(requested) > 0 {
+		if c.allowAllHeaders && explicit {
+			// "*" is treated as a literal header name in requests with credentials, so echo the request.
+			h.Set("Access-Control-Allow-Headers", strings.Join(requested, ","))
+		} else {
+			h.Set("Access-Control-Allow-Headers", c.allowHeaders)
+		}
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	if c.opts.AllowPrivateNetwork && r.Header.Get("Access-Control-Request-Private-Network") == "true" {
+		h.Set("Access-Control-Allow-Private-Network", "true")
+	}
+}
+
+func (c *cors) actual(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	allowed, explicit := c.checkOrigin(r, origin)
+	if !allowed {
+		return
+	}
+
+	c.setOrigin(h, origin, explicit)
+	if c.exposeHeaders != "" {
+		h.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+	}
+}
+
+func parseHeaderList(list string) []string {
+	if list == "" {
+		return nil
 	}
-	for _, v := range allowedOrigins {
-		if origin == v || v == "*" {
-			return true
+	var headers []string
+	for _, header := range strings.Split(list, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, http.CanonicalHeaderKey(header))
 		}
 	}
-	return false
+	return headers
 }
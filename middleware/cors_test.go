@@ -0,0 +1,151 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gromey/proto-rest/middleware"
+)
+
+func TestAllowCORS(t *testing.T) {
+	opts := &middleware.CORSOptions{
+		AllowedOrigins:        []string{"https://example.com", "https://*.example.org"},
+		AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`^https://app-\d+\.example\.net$`)},
+		AllowMethods:          []string{http.MethodGet, http.MethodPut},
+		AllowHeaders:          []string{"Content-Type"},
+		ExposeHeaders:         []string{"X-Request-Id"},
+		MaxAge:                600,
+		AllowCredentials:      true,
+		AllowPrivateNetwork:   true,
+	}
+
+	var tests = []struct {
+		name       string
+		opts       *middleware.CORSOptions
+		method     string
+		header     map[string]string
+		expStatus  int
+		expHeader  map[string]string
+		expHandled bool
+	}{
+		{
+			name:       "actual request from allowed origin",
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://example.com"},
+			expStatus:  http.StatusOK,
+			expHeader:  map[string]string{"Access-Control-Allow-Origin": "https://example.com", "Access-Control-Allow-Credentials": "true", "Access-Control-Expose-Headers": "X-Request-Id", "Vary": "Origin"},
+			expHandled: true,
+		},
+		{
+			name:       "actual request from wildcard subdomain",
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://api.example.org"},
+			expStatus:  http.StatusOK,
+			expHeader:  map[string]string{"Access-Control-Allow-Origin": "https://api.example.org"},
+			expHandled: true,
+		},
+		{
+			name:       "actual request from regexp origin",
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://app-42.example.net"},
+			expStatus:  http.StatusOK,
+			expHeader:  map[string]string{"Access-Control-Allow-Origin": "https://app-42.example.net"},
+			expHandled: true,
+		},
+		{
+			name:       "actual request from disallowed origin",
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://evil.com"},
+			expStatus:  http.StatusOK,
+			expHeader:  map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"},
+			expHandled: true,
+		},
+		{
+			name:       "OPTIONS without request method is not a preflight",
+			method:     http.MethodOptions,
+			header:     map[string]string{"Origin": "https://example.com"},
+			expStatus:  http.StatusOK,
+			expHeader:  map[string]string{"Access-Control-Allow-Methods": ""},
+			expHandled: true,
+		},
+		{
+			name:      "preflight request",
+			method:    http.MethodOptions,
+			header:    map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "content-type", "Access-Control-Request-Private-Network": "true"},
+			expStatus: http.StatusOK,
+			expHeader: map[string]string{"Access-Control-Allow-Origin": "https://example.com", "Access-Control-Allow-Methods": "GET,PUT", "Access-Control-Allow-Headers": "Content-Type", "Access-Control-Max-Age": "600", "Access-Control-Allow-Private-Network": "true"},
+		},
+		{
+			name:      "preflight request with disallowed method",
+			method:    http.MethodOptions,
+			header:    map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "DELETE"},
+			expStatus: http.StatusOK,
+			expHeader: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:      "preflight request with disallowed header",
+			method:    http.MethodOptions,
+			header:    map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Custom"},
+			expStatus: http.StatusOK,
+			expHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "any origin with credentials",
+			opts:       &middleware.CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://example.com"},
+			expStatus:  http.StatusOK,
+			expHeader:  map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Credentials": ""},
+			expHandled: true,
+		},
+		{
+			name:      "custom preflight status",
+			opts:      &middleware.CORSOptions{AllowedOrigins: []string{"*"}, OptionsSuccessStatus: http.StatusNoContent},
+			method:    http.MethodOptions,
+			header:    map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "GET"},
+			expStatus: http.StatusNoContent,
+			expHeader: map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name: "origin validator",
+			opts: &middleware.CORSOptions{OriginValidator: func(_ *http.Request, origin string) bool {
+				return origin == "https://validated.com"
+			}},
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://validated.com"},
+			expStatus:  http.StatusOK,
+			expHeader:  map[string]string{"Access-Control-Allow-Origin": "https://validated.com"},
+			expHandled: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := test.opts
+			if o == nil {
+				o = opts
+			}
+
+			var handled bool
+			h := middleware.AllowCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handled = true
+			}), o)
+
+			r := httptest.NewRequest(test.method, "/path", nil)
+			for k, v := range test.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			equal(t, test.expStatus, w.Code)
+			equal(t, test.expHandled, handled)
+			for k, v := range test.expHeader {
+				equal(t, v, w.Header().Get(k))
+			}
+		})
+	}
+}
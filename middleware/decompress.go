@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gromey/proto-rest/errors"
+)
+
+// Decompress inflates request bodies sent with Content-Encoding gzip or deflate.
+// Reading more than maxSize decompressed bytes returns errors.ErrBodyTooLarge, which protects against zip bombs.
+// If maxSize is not positive, it defaults to 10 MiB.
+// Requests with any other content coding are rejected with 415 Unsupported Media Type.
+func Decompress(maxSize int64) func(http.Handler) http.Handler {
+	if maxSize <= 0 {
+		maxSize = 10 << 20
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var zr io.ReadCloser
+			switch encoding {
+			case "gzip", "x-gzip":
+				gr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+					return
+				}
+				zr = gr
+			case "deflate":
+				zr = flate.NewReader(r.Body)
+			default:
+				w.Header().Set("Accept-Encoding", "gzip, deflate")
+				http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+				return
+			}
+
+			r = r.Clone(r.Context())
+			r.Body = &limitedBody{r: zr, c: r.Body, limit: maxSize}
+			r.ContentLength = -1
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitedBody reads from r and fails with errors.ErrBodyTooLarge after limit bytes,
+// or at once when the known length of the body exceeds limit.
+type limitedBody struct {
+	r          io.Reader
+	c          io.Closer
+	limit      int64
+	length     int64 // The Content-Length of the body, not positive if unknown.
+	read       int64
+	adjustable bool // Set by MaxBytes, so a route can override the limit of an outer MaxBytes.
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.length > b.limit {
+		return 0, errors.ErrBodyTooLarge
+	}
+	remaining := b.limit - b.read
+	if remaining <= 0 {
+		// Probe for one more byte to tell an exact fit from an overflow.
+		var probe [1]byte
+		n, err := b.r.Read(probe[:])
+		if n > 0 {
+			return 0, errors.ErrBodyTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	// Close the decompressor too, if r is one.
+	if rc, ok := b.r.(io.Closer); ok && rc != b.c {
+		_ = rc.Close()
+	}
+	return b.c.Close()
+}
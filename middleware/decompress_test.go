@@ -0,0 +1,69 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gromey/proto-rest/errors"
+	"github.com/gromey/proto-rest/middleware"
+)
+
+func TestDecompress(t *testing.T) {
+	body := strings.Repeat("a", 100)
+
+	var tests = []struct {
+		name      string
+		maxSize   int64
+		expBody   string
+		expStatus int
+	}{
+		{
+			name:      "within limit",
+			maxSize:   100,
+			expBody:   body,
+			expStatus: http.StatusOK,
+		},
+		{
+			name:      "exceeds limit",
+			maxSize:   99,
+			expStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:      "default limit",
+			expBody:   body,
+			expStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := middleware.Decompress(test.maxSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				equal(t, "", r.Header.Get("Content-Encoding"))
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					equal(t, errors.ErrBodyTooLarge, err)
+					w.WriteHeader(errors.ErrBodyTooLarge.Code())
+					return
+				}
+				equal(t, test.expBody, string(b))
+			}))
+
+			buf := new(strings.Builder)
+			zw := gzip.NewWriter(buf)
+			_, _ = io.WriteString(zw, body)
+			_ = zw.Close()
+
+			r := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader(buf.String()))
+			r.Header.Set("Content-Encoding", "gzip")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			equal(t, test.expStatus, w.Code)
+		})
+	}
+}
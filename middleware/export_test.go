@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"time"
+)
+
+// AdaptiveLimiter exposes the limiter of ConcurrencyLimit to the tests.
+type AdaptiveLimiter struct {
+	l *limiter
+}
+
+func NewAdaptiveLimiter(opts *ConcurrencyLimitOptions) *AdaptiveLimiter {
+	return &AdaptiveLimiter{l: newLimiter(opts)}
+}
+
+// Observe records a request that took latency.
+func (a *AdaptiveLimiter) Observe(latency time.Duration) {
+	a.l.mu.Lock()
+	a.l.inFlight++
+	a.l.mu.Unlock()
+	a.l.release(latency)
+}
+
+// Limit returns the current limit.
+func (a *AdaptiveLimiter) Limit() int {
+	a.l.mu.Lock()
+	defer a.l.mu.Unlock()
+	return int(a.l.limit)
+}
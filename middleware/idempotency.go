@@ -0,0 +1,239 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gromey/proto-rest/errors"
+)
+
+// IdempotencyRecord represents the state of a request made with an Idempotency-Key.
+type IdempotencyRecord struct {
+	Fingerprint string      // The digest of the method, path and body of the first request.
+	Done        bool        // Whether the first request has completed.
+	StatusCode  int         // The status code of the stored response.
+	Header      http.Header // The headers of the stored response.
+	Body        []byte      // The body of the stored response.
+	TooLarge    bool        // The response exceeded MaxBodySize, so it was not stored and can't be replayed.
+}
+
+// An IdempotencyStore keeps records for the Idempotency middleware. It must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Start atomically reserves key for a request with the given fingerprint.
+	// If the key is already known, its record is returned and started is false.
+	Start(key, fingerprint string) (record *IdempotencyRecord, started bool)
+	// Complete stores the response for a reserved key.
+	Complete(key string, record *IdempotencyRecord)
+	// Release drops the reservation of key, so the request can be retried.
+	Release(key string)
+}
+
+type memoryIdempotencyStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	records   map[string]*memoryIdempotencyItem
+	nextSweep time.Time
+}
+
+// idempotencySweepInterval is how often the memory store drops expired records.
+const idempotencySweepInterval = time.Minute
+
+type memoryIdempotencyItem struct {
+	record  *IdempotencyRecord
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore returns an in-memory IdempotencyStore that keeps records for ttl.
+func NewMemoryIdempotencyStore(ttl time.Duration) IdempotencyStore {
+	return &memoryIdempotencyStore{ttl: ttl, records: make(map[string]*memoryIdempotencyItem)}
+}
+
+// Start reserves key unless a record that has not expired exists.
+func (s *memoryIdempotencyStore) Start(key, fingerprint string) (*IdempotencyRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if item, ok := s.records[key]; ok && item.expires.After(now) {
+		return item.record, false
+	}
+
+	// Drop expired records while holding the lock anyway, but not on every call.
+	if !now.Before(s.nextSweep) {
+		for k, item := range s.records {
+			if !item.expires.After(now) {
+				delete(s.records, k)
+			}
+		}
+		s.nextSweep = now.Add(idempotencySweepInterval)
+	}
+
+	s.records[key] = &memoryIdempotencyItem{record: &IdempotencyRecord{Fingerprint: fingerprint}, expires: now.Add(s.ttl)}
+	return nil, true
+}
+
+// Complete stores the response for key.
+func (s *memoryIdempotencyStore) Complete(key string, record *IdempotencyRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = &memoryIdempotencyItem{record: record, expires: time.Now().Add(s.ttl)}
+}
+
+// Release removes key.
+func (s *memoryIdempotencyStore) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+}
+
+// IdempotencyOptions represents options for configuring the Idempotency middleware.
+type IdempotencyOptions struct {
+	Store          IdempotencyStore // Where records are kept. Defaults to NewMemoryIdempotencyStore(24 * time.Hour).
+	Header         string           // The request header that carries the key. Defaults to "Idempotency-Key".
+	Methods        []string         // The methods the middleware applies to. Defaults to POST and PATCH.
+	MaxBodySize    int64            // Responses with larger bodies are not stored and can't be replayed. Defaults to 1 MiB.
+	MaxRequestSize int64            // Requests with larger bodies are rejected with 413. Defaults to 1 MiB.
+}
+
+// IdempotencyReplayedHeader is set to "true" on replayed responses.
+const IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+// Idempotency makes retries of unsafe requests carrying an Idempotency-Key header safe.
+// The first response for a key is stored and replayed for repeated requests with the same method, path and body.
+// A repeat that arrives while the first request is in flight gets 409 Conflict,
+// a repeat with a different payload gets 422 Unprocessable Entity.
+// Keys are scoped to the authenticated principal, if any. Server errors are not stored, so they can be retried.
+// A repeat of a request whose response was larger than MaxBodySize gets 409 Conflict, since it can't be replayed.
+func Idempotency(opts *IdempotencyOptions) func(http.Handler) http.Handler {
+	if opts == nil {
+		opts = new(IdempotencyOptions)
+	}
+	store := opts.Store
+	if store == nil {
+		store = NewMemoryIdempotencyStore(24 * time.Hour)
+	}
+	header := opts.Header
+	if header == "" {
+		header = "Idempotency-Key"
+	}
+	methods := opts.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	maxBodySize := opts.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = 1 << 20
+	}
+	maxRequest := opts.MaxRequestSize
+	if maxRequest <= 0 {
+		maxRequest = 1 << 20
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(header)
+			if key == "" || !contains(methods, r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if principal, ok := Principal(r.Context()); ok {
+				key = principal + ":" + key
+			}
+
+			var body []byte
+			if r.Body != nil {
+				var err error
+				if body, err = io.ReadAll(io.LimitReader(r.Body, maxRequest+1)); err != nil {
+					writeBodyError(w, err)
+					return
+				}
+				if int64(len(body)) > maxRequest {
+					writeBodyError(w, errors.ErrBodyTooLarge)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			sum := sha256.New()
+			sum.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+			sum.Write(body)
+			fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+			if record, started := store.Start(key, fingerprint); !started {
+				switch {
+				case record.Fingerprint != fingerprint:
+					http.Error(w, "Idempotency-Key reused with a different request", http.StatusUnprocessableEntity)
+				case !record.Done:
+					http.Error(w, "A request with the same Idempotency-Key is in progress", http.StatusConflict)
+				case record.TooLarge:
+					http.Error(w, "The response to the request with the same Idempotency-Key can't be replayed", http.StatusConflict)
+				default:
+					h := w.Header()
+					for name, values := range record.Header {
+						h[name] = values
+					}
+					h.Set(IdempotencyReplayedHeader, "true")
+					w.WriteHeader(record.StatusCode)
+					_, _ = w.Write(record.Body)
+				}
+				return
+			}
+
+			rec := &cacheRecorder{ResponseWriter: w, limit: maxBodySize}
+			var completed bool
+
+			// Only the headers set by the handler are stored, not the per-request ones of outer middlewares,
+			// such as request IDs or the CORS headers for the origin of the first caller.
+			before := w.Header().Clone()
+
+			// Runs even if the handler panics, so the key doesn't stay reserved.
+			defer func() {
+				if rec.status == 0 {
+					rec.status = http.StatusOK
+				}
+				if !completed || rec.status >= 500 {
+					store.Release(key)
+					return
+				}
+				if rec.overflow {
+					// The side effect happened, so a retry must not run the handler again.
+					store.Complete(key, &IdempotencyRecord{Fingerprint: fingerprint, Done: true, TooLarge: true})
+					return
+				}
+				store.Complete(key, &IdempotencyRecord{
+					Fingerprint: fingerprint,
+					Done:        true,
+					StatusCode:  rec.status,
+					Header:      handlerHeaders(before, rec.Header()),
+					Body:        rec.buf.Bytes(),
+				})
+			}()
+
+			next.ServeHTTP(rec, r)
+			completed = true
+		})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeBodyError(w http.ResponseWriter, err error) {
+	if e, ok := err.(errors.Error); ok {
+		http.Error(w, err.Error(), e.Code())
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+}
@@ -0,0 +1,148 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gromey/proto-rest/middleware"
+)
+
+func TestIdempotency(t *testing.T) {
+	type request struct {
+		body        string
+		expStatus   int
+		expBody     string
+		expReplayed string
+	}
+
+	var tests = []struct {
+		name     string
+		opts     *middleware.IdempotencyOptions
+		status   int
+		body     string
+		requests []request
+		expCalls int32
+	}{
+		{
+			name:   "stored response is replayed",
+			status: http.StatusCreated,
+			body:   "created",
+			requests: []request{
+				{body: "a", expStatus: http.StatusCreated, expBody: "created"},
+				{body: "a", expStatus: http.StatusCreated, expBody: "created", expReplayed: "true"},
+			},
+			expCalls: 1,
+		},
+		{
+			name:   "key reused with a different request",
+			status: http.StatusCreated,
+			body:   "created",
+			requests: []request{
+				{body: "a", expStatus: http.StatusCreated, expBody: "created"},
+				{body: "b", expStatus: http.StatusUnprocessableEntity},
+			},
+			expCalls: 1,
+		},
+		{
+			name:   "key released on server error",
+			status: http.StatusInternalServerError,
+			body:   "error",
+			requests: []request{
+				{body: "a", expStatus: http.StatusInternalServerError, expBody: "error"},
+				{body: "a", expStatus: http.StatusInternalServerError, expBody: "error"},
+			},
+			expCalls: 2,
+		},
+		{
+			name:   "response too large to replay",
+			opts:   &middleware.IdempotencyOptions{MaxBodySize: 4},
+			status: http.StatusCreated,
+			body:   "created",
+			requests: []request{
+				{body: "a", expStatus: http.StatusCreated, expBody: "created"},
+				{body: "a", expStatus: http.StatusConflict},
+				{body: "b", expStatus: http.StatusUnprocessableEntity},
+			},
+			expCalls: 1,
+		},
+		{
+			name:   "request too large",
+			opts:   &middleware.IdempotencyOptions{MaxRequestSize: 4},
+			status: http.StatusCreated,
+			requests: []request{
+				{body: "large", expStatus: http.StatusRequestEntityTooLarge},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int32
+			h := middleware.Idempotency(test.opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.Header().Set("Location", "/path/1")
+				w.WriteHeader(test.status)
+				_, _ = io.WriteString(w, test.body)
+			}))
+
+			for i, req := range test.requests {
+				r := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader(req.body))
+				r.Header.Set("Idempotency-Key", "key")
+				w := httptest.NewRecorder()
+				// Set by an outer middleware for each request, it is not replayed.
+				requestID := strconv.Itoa(i)
+				w.Header().Set("X-Request-Id", requestID)
+
+				h.ServeHTTP(w, r)
+
+				equal(t, requestID, w.Header().Get("X-Request-Id"))
+				equal(t, req.expStatus, w.Code)
+				if req.expBody != "" {
+					equal(t, req.expBody, w.Body.String())
+				}
+				equal(t, req.expReplayed, w.Header().Get(middleware.IdempotencyReplayedHeader))
+				if req.expReplayed != "" {
+					equal(t, "/path/1", w.Header().Get("Location"))
+				}
+			}
+
+			equal(t, test.expCalls, atomic.LoadInt32(&calls))
+		})
+	}
+
+	t.Run("same key in flight", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		h := middleware.Idempotency(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		newRequest := func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("a"))
+			r.Header.Set("Idempotency-Key", "key")
+			return r
+		}
+
+		first := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			h.ServeHTTP(first, newRequest())
+		}()
+		<-started
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, newRequest())
+		equal(t, http.StatusConflict, w.Code)
+
+		close(release)
+		<-done
+		equal(t, http.StatusCreated, first.Code)
+	})
+}
@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gromey/proto-rest/errors"
+	"github.com/gromey/proto-rest/server"
+)
+
+// Priority is the priority class of a request under the ConcurrencyLimit middleware.
+type Priority uint8
+
+const (
+	PriorityLow      Priority = iota // Served after all other queued requests.
+	PriorityNormal                   // The default priority.
+	PriorityHigh                     // Served before normal and low priority requests.
+	PriorityCritical                 // Bypasses the limiter, e.g. health checks.
+)
+
+// ConcurrencyLimitOptions represents options for configuring the ConcurrencyLimit middleware.
+type ConcurrencyLimitOptions struct {
+	MaxInFlight  int                            // The maximum number of requests handled at once. Required.
+	MaxQueue     int                            // The maximum number of requests waiting for a slot. Zero rejects at once.
+	QueueTimeout time.Duration                  // How long a request waits for a slot. Defaults to 1 second.
+	Priority     func(r *http.Request) Priority // Classifies requests. Defaults to PriorityNormal for all.
+	RetryAfter   time.Duration                  // The value of the Retry-After header of rejected requests. Defaults to 1 second.
+	Server       server.Server                  // Writes the error document with its Coder. If nil, a plain text error is written.
+
+	// Adaptive lowers the limit when the observed latency grows above the best latency seen recently,
+	// and raises it back up to MaxInFlight when the latency recovers.
+	Adaptive    bool
+	MinInFlight int // The lowest adaptive limit. Defaults to 1.
+}
+
+// ConcurrencyLimit limits the number of requests handled at once to shed load during traffic spikes.
+// Excess requests wait in a bounded queue, higher priority classes first, and are rejected
+// with 503 Service Unavailable and Retry-After when the queue is full or the wait times out.
+func ConcurrencyLimit(opts *ConcurrencyLimitOptions) func(http.Handler) http.Handler {
+	l := newLimiter(opts)
+
+	retryAfter := opts.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+	retryAfterSeconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			priority := PriorityNormal
+			if opts.Priority != nil {
+				priority = opts.Priority(r)
+			}
+			if priority >= PriorityCritical {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !l.acquire(r, priority) {
+				w.Header().Set("Retry-After", retryAfterSeconds)
+				writeError(w, opts.Server, errors.NewProblem(http.StatusServiceUnavailable, "server is overloaded"))
+				return
+			}
+
+			start := time.Now()
+			defer func() { l.release(time.Since(start)) }()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type limiter struct {
+	opts    *ConcurrencyLimitOptions
+	timeout time.Duration
+	minimum float64
+
+	mu       sync.Mutex
+	inFlight int
+	limit    float64
+	queued   int
+	waiters  [PriorityCritical]*list.List
+
+	// Adaptive limiting state.
+	minLatency time.Duration
+	avgLatency float64
+	samples    int
+}
+
+type waiter struct {
+	ready chan struct{}
+}
+
+func newLimiter(opts *ConcurrencyLimitOptions) *limiter {
+	l := &limiter{opts: opts, timeout: opts.QueueTimeout, limit: float64(opts.MaxInFlight), minimum: float64(opts.MinInFlight)}
+	if l.timeout <= 0 {
+		l.timeout = time.Second
+	}
+	if l.minimum < 1 {
+		l.minimum = 1
+	}
+	for i := range l.waiters {
+		l.waiters[i] = list.New()
+	}
+	return l
+}
+
+// acquire takes a slot, waiting in the queue if necessary. It reports whether the slot was taken.
+func (l *limiter) acquire(r *http.Request, priority Priority) bool {
+	l.mu.Lock()
+	if l.inFlight < int(l.limit) && l.queued == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return true
+	}
+	if l.queued >= l.opts.MaxQueue {
+		l.mu.Unlock()
+		return false
+	}
+	w := &waiter{ready: make(chan struct{})}
+	el := l.waiters[priority].PushBack(w)
+	l.queued++
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+		return true
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-w.ready:
+		// The slot was granted while giving up, hand it on.
+		l.inFlight--
+		l.grant()
+	default:
+		l.waiters[priority].Remove(el)
+		l.queued--
+	}
+	return false
+}
+
+// release frees a slot and hands it to the highest priority waiter.
+func (l *limiter) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	if l.opts.Adaptive {
+		l.adapt(latency)
+	}
+	l.grant()
+}
+
+// grant hands free slots to waiters. The caller must hold the lock.
+func (l *limiter) grant() {
+	for i := len(l.waiters) - 1; i >= 0 && l.inFlight < int(l.limit); {
+		el := l.waiters[i].Front()
+		if el == nil {
+			i--
+			continue
+		}
+		l.waiters[i].Remove(el)
+		l.queued--
+		l.inFlight++
+		close(el.Value.(*waiter).ready)
+	}
+}
+
+// adapt updates the limit with the gradient of the best latency to the average one. The caller must hold the lock.
+func (l *limiter) adapt(latency time.Duration) {
+	l.samples++
+	if l.minLatency == 0 || latency < l.minLatency {
+		l.minLatency = latency
+	}
+	if l.avgLatency == 0 {
+		l.avgLatency = float64(latency)
+	} else {
+		l.avgLatency = 0.9*l.avgLatency + 0.1*float64(latency)
+	}
+	// Forget the best latency from time to time, so the limiter notices when it has permanently changed.
+	if l.samples%1000 == 0 {
+		l.minLatency = time.Duration(l.avgLatency)
+	}
+	if l.avgLatency == 0 {
+		return
+	}
+
+	gradient := float64(l.minLatency) / l.avgLatency
+	gradient = math.Max(0.5, math.Min(1, gradient))
+
+	limit := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = math.Max(l.minimum, math.Min(float64(l.opts.MaxInFlight), limit))
+}
@@ -0,0 +1,96 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/middleware"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+
+	h := middleware.ConcurrencyLimit(&middleware.ConcurrencyLimitOptions{
+		MaxInFlight:  1,
+		MaxQueue:     1,
+		QueueTimeout: time.Second,
+		Priority: func(r *http.Request) middleware.Priority {
+			if r.URL.Path == "/healthz" {
+				return middleware.PriorityCritical
+			}
+			return middleware.PriorityNormal
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+	}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve("/slow").Code
+		}()
+	}
+
+	// One request holds the slot, the other waits in the queue.
+	<-started
+	time.Sleep(10 * time.Millisecond)
+
+	w := serve("/fast")
+	equal(t, http.StatusServiceUnavailable, w.Code)
+	equal(t, "1", w.Header().Get("Retry-After"))
+
+	equal(t, http.StatusOK, serve("/healthz").Code)
+
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		equal(t, http.StatusOK, code)
+	}
+}
+
+func TestConcurrencyLimit_Adaptive(t *testing.T) {
+	l := middleware.NewAdaptiveLimiter(&middleware.ConcurrencyLimitOptions{MaxInFlight: 100, MinInFlight: 10, Adaptive: true})
+
+	observe := func(latency time.Duration, n int) {
+		for i := 0; i < n; i++ {
+			l.Observe(latency)
+			if limit := l.Limit(); limit < 10 || limit > 100 {
+				t.Fatalf("limit %d out of bounds", limit)
+			}
+		}
+	}
+
+	// Steady latency keeps the limit at the maximum.
+	observe(10*time.Millisecond, 50)
+	equal(t, 100, l.Limit())
+
+	// Growing latency lowers the limit, down to the minimum.
+	observe(100*time.Millisecond, 5)
+	shrunk := l.Limit()
+	equal(t, true, shrunk < 100)
+	observe(100*time.Millisecond, 200)
+	equal(t, 10, l.Limit())
+
+	// Recovered latency raises the limit again, up to the maximum.
+	observe(10*time.Millisecond, 40)
+	equal(t, true, l.Limit() > 10)
+	observe(10*time.Millisecond, 300)
+	equal(t, 100, l.Limit())
+}
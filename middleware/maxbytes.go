@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// MaxBytes limits the size of request bodies to limit bytes.
+// Reading a body with a larger Content-Length, or reading past the limit, returns errors.ErrBodyTooLarge,
+// which the Coder's Decode passes through, so the handler answers 413 Request Entity Too Large.
+// Wrap a route with another MaxBytes to override the limit for it, the inner limit takes precedence.
+func MaxBytes(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if b, ok := r.Body.(*limitedBody); ok && b.adjustable {
+				b.limit = limit
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r = r.Clone(r.Context())
+			r.Body = &limitedBody{r: r.Body, c: r.Body, limit: limit, length: r.ContentLength, adjustable: true}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gromey/proto-rest/errors"
+	"github.com/gromey/proto-rest/middleware"
+)
+
+func TestMaxBytes(t *testing.T) {
+	var tests = []struct {
+		name      string
+		limits    []int64 // From the outermost to the innermost MaxBytes.
+		size      int
+		expStatus int
+	}{
+		{
+			name:      "body within limit",
+			limits:    []int64{50},
+			size:      50,
+			expStatus: http.StatusOK,
+		},
+		{
+			name:      "body too large",
+			limits:    []int64{50},
+			size:      51,
+			expStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:      "inner limit raises the outer one",
+			limits:    []int64{10, 100},
+			size:      50,
+			expStatus: http.StatusOK,
+		},
+		{
+			name:      "inner limit lowers the outer one",
+			limits:    []int64{100, 10},
+			size:      50,
+			expStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, test := range tests {
+		for _, chunked := range []bool{false, true} {
+			name := test.name
+			if chunked {
+				name += " of unknown length"
+			}
+			t.Run(name, func(t *testing.T) {
+				var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					p, err := io.ReadAll(r.Body)
+					if err != nil {
+						e, ok := err.(errors.Error)
+						equal(t, true, ok)
+						w.WriteHeader(e.Code())
+						return
+					}
+					equal(t, test.size, len(p))
+				})
+				for i := len(test.limits) - 1; i >= 0; i-- {
+					h = middleware.MaxBytes(test.limits[i])(h)
+				}
+
+				var body io.Reader = strings.NewReader(strings.Repeat("a", test.size))
+				if chunked {
+					body = io.NopCloser(body)
+				}
+				r := httptest.NewRequest(http.MethodPost, "/path", body)
+				if chunked {
+					r.ContentLength = -1
+				}
+				w := httptest.NewRecorder()
+
+				h.ServeHTTP(w, r)
+
+				equal(t, test.expStatus, w.Code)
+			})
+		}
+	}
+}
@@ -1,14 +1,19 @@
 package middleware
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"runtime/debug"
 	"time"
 
+	"github.com/gromey/proto-rest/errors"
 	"github.com/gromey/proto-rest/logger"
+	"github.com/gromey/proto-rest/server"
 )
 
 // Sequencer chains middleware functions in a chain.
@@ -20,34 +25,143 @@ func Sequencer(baseHandler http.Handler, mws ...func(http.Handler) http.Handler)
 }
 
 // Timer measures the time taken by http.HandlerFunc.
+// If the request was authenticated by an inner middleware, the principal is included in the log.
 func Timer(logLevel logger.Level) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			holder := new(principalHolder)
 			defer func(start time.Time) {
 				if logger.InLevel(logLevel) {
+					if principal := holder.get(); principal != "" {
+						logLevel.Printf()("%s %s %s principal=%s", r.Method, r.RequestURI, time.Since(start), principal)
+						return
+					}
 					logLevel.Printf()("%s %s %s", r.Method, r.RequestURI, time.Since(start))
 				}
 			}(time.Now())
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalHolderKey{}, holder)))
 		})
 	}
 }
 
 // PanicCatcher handles panics in http.HandlerFunc.
+// It is PanicCatcherWithOptions with no options.
 func PanicCatcher(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if rec := recover(); rec != nil {
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	return PanicCatcherWithOptions(nil)(next)
+}
+
+// PanicCatcherOptions represents options for configuring the PanicCatcher middleware.
+type PanicCatcherOptions struct {
+	// Writes the 500 error document with its Coder. If nil, a plain text error is written.
+	Server server.Server
+	// Called with the panic value, the stack trace and the request, e.g. to report the panic to an error tracker.
+	Report func(rec any, stack []byte, r *http.Request)
+}
+
+// PanicCatcherWithOptions handles panics in http.HandlerFunc: it logs the stack, calls the Report hook
+// and writes a 500 error unless the response has already started.
+// http.ErrAbortHandler is re-panicked, so net/http aborts the response silently as intended.
+func PanicCatcherWithOptions(opts *PanicCatcherOptions) func(http.Handler) http.Handler {
+	if opts == nil {
+		opts = new(PanicCatcherOptions)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &startedWriter{ResponseWriter: w}
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				stack := debug.Stack()
 				if logger.InLevel(logger.LevelError) {
-					logger.Error(string(debug.Stack()))
+					logger.Errorf("panic: %v\n%s", rec, stack)
 				}
-			}
-		}()
-		next.ServeHTTP(w, r)
-	})
+				if opts.Report != nil {
+					opts.Report(rec, stack, r)
+				}
+
+				if !sw.started {
+					writeError(w, opts.Server, errors.NewProblem(http.StatusInternalServerError, ""))
+				}
+			}()
+			next.ServeHTTP(sw.wrap(), r)
+		})
+	}
+}
+
+// startedWriter records whether the response has started.
+type startedWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (sw *startedWriter) WriteHeader(statusCode int) {
+	if statusCode >= 200 {
+		sw.started = true
+	}
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *startedWriter) Write(p []byte) (int, error) {
+	sw.started = true
+	return sw.ResponseWriter.Write(p)
 }
 
+// Unwrap returns the underlying http.ResponseWriter.
+func (sw *startedWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// wrap returns sw as an http.ResponseWriter that implements http.Flusher and http.Hijacker
+// only if the underlying writer does.
+func (sw *startedWriter) wrap() http.ResponseWriter {
+	_, flusher := sw.ResponseWriter.(http.Flusher)
+	_, hijacker := sw.ResponseWriter.(http.Hijacker)
+	switch {
+	case flusher && hijacker:
+		return startedFlushHijacker{sw}
+	case flusher:
+		return startedFlusher{sw}
+	case hijacker:
+		return startedHijacker{sw}
+	}
+	return sw
+}
+
+func (sw *startedWriter) flush() {
+	sw.started = true
+	sw.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (sw *startedWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	sw.started = true
+	return sw.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type startedFlusher struct{ *startedWriter }
+
+// Flush flushes the underlying writer.
+func (sw startedFlusher) Flush() { sw.flush() }
+
+type startedHijacker struct{ *startedWriter }
+
+// Hijack lets the caller take over the connection.
+func (sw startedHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) { return sw.hijack() }
+
+type startedFlushHijacker struct{ *startedWriter }
+
+// Flush flushes the underlying writer.
+func (sw startedFlushHijacker) Flush() { sw.flush() }
+
+// Hijack lets the caller take over the connection.
+func (sw startedFlushHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) { return sw.hijack() }
+
 // DumpHttp dumps the HTTP request and response, and prints out with logFunc.
 func DumpHttp(logLevel logger.Level) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -79,3 +193,43 @@ func DumpHttp(logLevel logger.Level) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// writeError writes the problem through srv, so the error document is encoded with its Coder.
+// If srv is nil, a plain text error is written.
+// The representation headers set for the response it replaces are removed.
+func writeError(w http.ResponseWriter, srv server.Server, problem *errors.Problem) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	if srv == nil {
+		http.Error(w, problem.Error(), problem.Code())
+		return
+	}
+	srv.WriteResponse(w, problem.Code(), problem)
+}
+
+// handlerHeaders returns the headers of after that are missing from before or have other values, i.e. the headers
+// a handler set on top of the snapshot taken before it ran, without those set by outer middlewares.
+func handlerHeaders(before, after http.Header) http.Header {
+	h := make(http.Header)
+	for name, values := range after {
+		if old, ok := before[name]; ok && equalValues(old, values) {
+			continue
+		}
+		h[name] = append([]string(nil), values...)
+	}
+	return h
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gromey/proto-rest/errors"
+	"github.com/gromey/proto-rest/middleware"
+)
+
+func TestPanicCatcherWithOptions(t *testing.T) {
+	var tests = []struct {
+		name      string
+		started   bool
+		expStatus int
+	}{
+		{
+			name:      "panic before response",
+			expStatus: http.StatusInternalServerError,
+		},
+		{
+			name:      "panic after response started",
+			started:   true,
+			expStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var reported any
+			var flusher, hijacker bool
+			h := middleware.PanicCatcherWithOptions(&middleware.PanicCatcherOptions{
+				Server: srvJSON,
+				Report: func(rec any, stack []byte, r *http.Request) {
+					reported = rec
+				},
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, flusher = w.(http.Flusher)
+				_, hijacker = w.(http.Hijacker)
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Header().Set("Content-Length", "100")
+				if test.started {
+					w.WriteHeader(http.StatusAccepted)
+				}
+				panic("boom")
+			}))
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/path", nil))
+
+			equal(t, "boom", reported)
+			// httptest.ResponseRecorder is an http.Flusher but not an http.Hijacker.
+			equal(t, true, flusher)
+			equal(t, false, hijacker)
+			equal(t, test.expStatus, w.Code)
+			if !test.started {
+				equal(t, "application/json", w.Header().Get("Content-Type"))
+				equal(t, "", w.Header().Get("Content-Encoding"))
+				equal(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+				problem := new(errors.Problem)
+				equal(t, nil, json.Unmarshal(w.Body.Bytes(), problem))
+				equal(t, http.StatusInternalServerError, problem.Status)
+			}
+		})
+	}
+}
+
+func TestPanicCatcher_ErrAbortHandler(t *testing.T) {
+	h := middleware.PanicCatcher(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		equal(t, http.ErrAbortHandler, recover())
+	}()
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/path", nil))
+}
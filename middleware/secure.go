@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/gromey/proto-rest/logger"
+)
+
+// NoncePlaceholder is replaced in the Content-Security-Policy with a nonce generated for each request.
+const NoncePlaceholder = "{nonce}"
+
+// SecureHeadersOptions represents options for configuring the SecureHeaders middleware.
+// An empty field means the corresponding header is not sent.
+type SecureHeadersOptions struct {
+	StrictTransportSecurity   string // Strict-Transport-Security, sent only over TLS unless ForceHSTS is set.
+	ForceHSTS                 bool   // Send Strict-Transport-Security over plain HTTP too, e.g. behind a TLS terminating proxy.
+	ContentSecurityPolicy     string // Content-Security-Policy, may contain NoncePlaceholder.
+	ContentTypeOptions        string // X-Content-Type-Options.
+	FrameOptions              string // X-Frame-Options.
+	ReferrerPolicy            string // Referrer-Policy.
+	PermissionsPolicy         string // Permissions-Policy.
+	CrossOriginOpenerPolicy   string // Cross-Origin-Opener-Policy.
+	CrossOriginEmbedderPolicy string // Cross-Origin-Embedder-Policy.
+	CrossOriginResourcePolicy string // Cross-Origin-Resource-Policy.
+}
+
+// DefaultSecureHeadersOptions returns options with safe defaults for an API.
+func DefaultSecureHeadersOptions() *SecureHeadersOptions {
+	return &SecureHeadersOptions{
+		StrictTransportSecurity:   "max-age=63072000; includeSubDomains",
+		ContentSecurityPolicy:     "default-src 'none'; frame-ancestors 'none'; base-uri 'none'",
+		ContentTypeOptions:        "nosniff",
+		FrameOptions:              "DENY",
+		ReferrerPolicy:            "no-referrer",
+		CrossOriginOpenerPolicy:   "same-origin",
+		CrossOriginResourcePolicy: "same-origin",
+	}
+}
+
+// With returns a copy of the options modified by f. Use it to derive per-route overrides.
+func (o SecureHeadersOptions) With(f func(*SecureHeadersOptions)) *SecureHeadersOptions {
+	f(&o)
+	return &o
+}
+
+type nonceKey struct{}
+
+// CSPNonce returns the Content-Security-Policy nonce generated for the request.
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(nonceKey{}).(string)
+	return nonce
+}
+
+// SecureHeaders sets security related response headers. If opts is nil, DefaultSecureHeadersOptions is used.
+// Wrapping a route with another SecureHeaders overrides the headers set by an outer one;
+// headers that are empty in the inner options are removed. The CSP nonce is shared between them.
+func SecureHeaders(opts *SecureHeadersOptions) func(http.Handler) http.Handler {
+	if opts == nil {
+		opts = DefaultSecureHeadersOptions()
+	}
+
+	headers := [...][2]string{
+		{"X-Content-Type-Options", opts.ContentTypeOptions},
+		{"X-Frame-Options", opts.FrameOptions},
+		{"Referrer-Policy", opts.ReferrerPolicy},
+		{"Permissions-Policy", opts.PermissionsPolicy},
+		{"Cross-Origin-Opener-Policy", opts.CrossOriginOpenerPolicy},
+		{"Cross-Origin-Embedder-Policy", opts.CrossOriginEmbedderPolicy},
+		{"Cross-Origin-Resource-Policy", opts.CrossOriginResourcePolicy},
+	}
+
+	useNonce := strings.Contains(opts.ContentSecurityPolicy, NoncePlaceholder)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			for _, header := range headers {
+				setOrDel(h, header[0], header[1])
+			}
+
+			if r.TLS != nil || opts.ForceHSTS {
+				setOrDel(h, "Strict-Transport-Security", opts.StrictTransportSecurity)
+			} else {
+				h.Del("Strict-Transport-Security")
+			}
+
+			csp := opts.ContentSecurityPolicy
+			if useNonce {
+				nonce := CSPNonce(r.Context())
+				if nonce == "" {
+					var err error
+					if nonce, err = newNonce(); err != nil {
+						if logger.InLevel(logger.LevelError) {
+							logger.Error("Can't generate CSP nonce. Error: ", err)
+						}
+						http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+						return
+					}
+					r = r.WithContext(context.WithValue(r.Context(), nonceKey{}, nonce))
+				}
+				csp = strings.ReplaceAll(csp, NoncePlaceholder, nonce)
+			}
+			setOrDel(h, "Content-Security-Policy", csp)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setOrDel(h http.Header, key, value string) {
+	if value == "" {
+		h.Del(key)
+		return
+	}
+	h.Set(key, value)
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
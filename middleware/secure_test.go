@@ -0,0 +1,107 @@
+package middleware_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gromey/proto-rest/middleware"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	withNonce := middleware.DefaultSecureHeadersOptions().With(func(o *middleware.SecureHeadersOptions) {
+		o.ContentSecurityPolicy = "script-src 'nonce-" + middleware.NoncePlaceholder + "'"
+	})
+
+	var tests = []struct {
+		name      string
+		mws       []func(http.Handler) http.Handler
+		tls       bool
+		expHeader map[string]string
+	}{
+		{
+			name: "default headers",
+			mws:  []func(http.Handler) http.Handler{middleware.SecureHeaders(nil)},
+			expHeader: map[string]string{
+				"Content-Security-Policy":      "default-src 'none'; frame-ancestors 'none'; base-uri 'none'",
+				"X-Content-Type-Options":       "nosniff",
+				"X-Frame-Options":              "DENY",
+				"Referrer-Policy":              "no-referrer",
+				"Cross-Origin-Opener-Policy":   "same-origin",
+				"Cross-Origin-Resource-Policy": "same-origin",
+				"Permissions-Policy":           "",
+				"Strict-Transport-Security":    "",
+			},
+		},
+		{
+			name:      "HSTS over TLS",
+			mws:       []func(http.Handler) http.Handler{middleware.SecureHeaders(nil)},
+			tls:       true,
+			expHeader: map[string]string{"Strict-Transport-Security": "max-age=63072000; includeSubDomains"},
+		},
+		{
+			name: "forced HSTS",
+			mws: []func(http.Handler) http.Handler{middleware.SecureHeaders(middleware.DefaultSecureHeadersOptions().With(func(o *middleware.SecureHeadersOptions) {
+				o.ForceHSTS = true
+			}))},
+			expHeader: map[string]string{"Strict-Transport-Security": "max-age=63072000; includeSubDomains"},
+		},
+		{
+			name: "per-route override and removal",
+			mws: []func(http.Handler) http.Handler{
+				middleware.SecureHeaders(middleware.DefaultSecureHeadersOptions().With(func(o *middleware.SecureHeadersOptions) {
+					o.FrameOptions = "SAMEORIGIN"
+					o.ReferrerPolicy = ""
+				})),
+				middleware.SecureHeaders(nil),
+			},
+			expHeader: map[string]string{
+				"X-Frame-Options":        "SAMEORIGIN",
+				"Referrer-Policy":        "",
+				"X-Content-Type-Options": "nosniff",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := middleware.Sequencer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), test.mws...)
+
+			r := httptest.NewRequest(http.MethodGet, "/path", nil)
+			if test.tls {
+				r.TLS = new(tls.ConnectionState)
+			}
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			equal(t, http.StatusOK, w.Code)
+			for k, v := range test.expHeader {
+				equal(t, v, w.Header().Get(k))
+			}
+		})
+	}
+
+	t.Run("CSP nonce", func(t *testing.T) {
+		var nonces []string
+		h := middleware.Sequencer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nonces = append(nonces, middleware.CSPNonce(r.Context()))
+		}), middleware.SecureHeaders(withNonce), middleware.SecureHeaders(withNonce))
+
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/path", nil))
+
+			nonce := nonces[i]
+			equal(t, true, nonce != "")
+			equal(t, "script-src 'nonce-"+nonce+"'", w.Header().Get("Content-Security-Policy"))
+			equal(t, false, strings.Contains(w.Header().Get("Content-Security-Policy"), middleware.NoncePlaceholder))
+		}
+
+		// The nested SecureHeaders share the nonce of a request, requests get different nonces.
+		equal(t, 2, len(nonces))
+		equal(t, false, nonces[0] == nonces[1])
+	})
+}
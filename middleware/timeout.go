@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gromey/proto-rest/errors"
+	"github.com/gromey/proto-rest/server"
+	"github.com/gromey/proto-rest/utils"
+)
+
+// TimeoutHeader is the default request header that carries the caller's remaining budget in grpc-timeout format.
+const TimeoutHeader = "X-Request-Timeout"
+
+// TimeoutOptions represents options for configuring the Timeout middleware.
+type TimeoutOptions struct {
+	Timeout    time.Duration // The deadline for handling a request. Zero means only the incoming budget applies.
+	Header     string        // The header that carries the incoming budget. Defaults to TimeoutHeader.
+	StatusCode int           // The status code written when the deadline is exceeded. Defaults to 503.
+	Server     server.Server // Writes the error document with its Coder. If nil, a plain text error is written.
+}
+
+// Timeout puts a deadline in the request context: the smaller of Timeout and the budget sent by the caller.
+// The handler runs with a buffered writer, so when the deadline is exceeded the error response is written
+// without racing the handler; its later writes return http.ErrHandlerTimeout.
+// Handlers can't be stopped, they should return when the context is done.
+func Timeout(opts *TimeoutOptions) func(http.Handler) http.Handler {
+	if opts == nil {
+		opts = new(TimeoutOptions)
+	}
+	header := opts.Header
+	if header == "" {
+		header = TimeoutHeader
+	}
+	statusCode := opts.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// A missing or invalid budget falls back to Timeout, an exhausted one fails at once.
+			timeout := opts.Timeout
+			if v := r.Header.Get(header); v != "" {
+				if d, err := utils.ParseTimeout(v); err == nil {
+					if d <= 0 {
+						writeError(w, opts.Server, errors.NewProblem(statusCode, "request timed out"))
+						return
+					}
+					if timeout == 0 || d < timeout {
+						timeout = d
+					}
+				}
+			}
+			if timeout == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{h: make(http.Header)}
+			done := make(chan struct{})
+			panicChan := make(chan any, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				for k, vv := range tw.h {
+					dst[k] = vv
+				}
+				if !tw.wroteHeader {
+					tw.code = http.StatusOK
+				}
+				w.WriteHeader(tw.code)
+				_, _ = w.Write(tw.buf.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				// A canceled context means the client has gone away, there is nobody to answer.
+				if stderrors.Is(ctx.Err(), context.DeadlineExceeded) {
+					writeError(w, opts.Server, errors.NewProblem(statusCode, "request timed out"))
+				}
+			}
+		})
+	}
+}
+
+// timeoutWriter buffers the response of a handler running under the Timeout middleware.
+type timeoutWriter struct {
+	h http.Header
+
+	mu          sync.Mutex
+	buf         bytes.Buffer
+	timedOut    bool
+	wroteHeader bool
+	code        int
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	tw.wroteHeader = true
+	tw.code = code
+}
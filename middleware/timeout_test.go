@@ -0,0 +1,107 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/coder"
+	"github.com/gromey/proto-rest/errors"
+	"github.com/gromey/proto-rest/middleware"
+	"github.com/gromey/proto-rest/server"
+)
+
+var srvJSON = server.New(coder.NewCoder("application/json", json.Marshal, json.Unmarshal))
+
+func TestTimeout(t *testing.T) {
+	var tests = []struct {
+		name      string
+		timeout   time.Duration
+		header    string
+		sleep     time.Duration
+		expStatus int
+	}{
+		{
+			name:      "handler in time",
+			timeout:   time.Second,
+			expStatus: http.StatusOK,
+		},
+		{
+			name:      "handler exceeds timeout",
+			timeout:   10 * time.Millisecond,
+			sleep:     time.Second,
+			expStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:      "handler exceeds incoming budget",
+			timeout:   time.Second,
+			header:    "10m",
+			sleep:     time.Second,
+			expStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:      "exhausted incoming budget",
+			header:    "0S",
+			expStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:      "invalid incoming budget",
+			timeout:   time.Second,
+			header:    "1000000000S",
+			expStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sleep := test.sleep
+			h := middleware.Timeout(&middleware.TimeoutOptions{Timeout: test.timeout, Server: srvJSON})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(sleep):
+				case <-r.Context().Done():
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/path", nil)
+			if test.header != "" {
+				r.Header.Set(middleware.TimeoutHeader, test.header)
+			}
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			equal(t, test.expStatus, w.Code)
+			if test.expStatus != http.StatusOK {
+				problem := new(errors.Problem)
+				equal(t, nil, json.Unmarshal(w.Body.Bytes(), problem))
+				equal(t, test.expStatus, problem.Status)
+			}
+		})
+	}
+}
+
+func TestTimeout_ClientGone(t *testing.T) {
+	started := make(chan struct{})
+	h := middleware.Timeout(&middleware.TimeoutOptions{Timeout: time.Second})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest(http.MethodGet, "/path", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	go func() {
+		<-started
+		cancel()
+	}()
+	h.ServeHTTP(w, r)
+
+	// Nothing is written for a client that has gone away.
+	equal(t, "", w.Body.String())
+}
@@ -0,0 +1,75 @@
+package pagination
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gromey/proto-rest/errors"
+)
+
+// ErrInvalidCursor is returned when a cursor is malformed or its signature doesn't match.
+var ErrInvalidCursor = errors.New(http.StatusBadRequest, "invalid cursor")
+
+// Cursors encodes the position of a page into opaque cursors and decodes them back.
+type Cursors struct {
+	key []byte
+}
+
+// NewCursors returns Cursors that sign cursors with HMAC-SHA256 and key, so clients can't forge them.
+// With an empty key, cursors are only encoded.
+func NewCursors(key []byte) *Cursors {
+	return &Cursors{key: key}
+}
+
+// Encode returns a cursor holding the JSON encoding of v, e.g. the sort key of the last item of a page.
+func (c *Cursors) Encode(v any) (string, error) {
+	p, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	cursor := base64.RawURLEncoding.EncodeToString(p)
+	if len(c.key) != 0 {
+		cursor += "." + base64.RawURLEncoding.EncodeToString(c.sign(p))
+	}
+	return cursor, nil
+}
+
+// Decode verifies the cursor and stores its value in the value pointed to by v.
+// It returns ErrInvalidCursor if the cursor wasn't returned by Encode with the same key.
+func (c *Cursors) Decode(cursor string, v any) error {
+	data, sig, signed := strings.Cut(cursor, ".")
+	if signed != (len(c.key) != 0) {
+		return ErrInvalidCursor
+	}
+
+	p, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+
+	if signed {
+		mac, err := base64.RawURLEncoding.DecodeString(sig)
+		if err != nil || !hmac.Equal(mac, c.sign(p)) {
+			return ErrInvalidCursor
+		}
+	}
+
+	d := json.NewDecoder(bytes.NewReader(p))
+	d.UseNumber()
+	if err = d.Decode(v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+func (c *Cursors) sign(p []byte) []byte {
+	h := hmac.New(sha256.New, c.key)
+	h.Write(p)
+	return h.Sum(nil)
+}
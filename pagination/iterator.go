@@ -0,0 +1,115 @@
+package pagination
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gromey/proto-rest/client"
+)
+
+// Iterator walks the items of all pages of a list endpoint that responds with a Page.
+type Iterator[T any] struct {
+	ctx   context.Context
+	c     client.Client
+	url   string
+	opts  *Options
+	f     func(*http.Request)
+	page  *Page[T]
+	items []T
+	item  T
+	err   error
+}
+
+// NewIterator returns an Iterator over the pages starting at rawURL, requested with c.
+// The next page is the next link of the Link header, or the URL with the cursor parameter set to the Next cursor.
+// To add additional data to the requests, use the optional function f.
+func NewIterator[T any](ctx context.Context, c client.Client, rawURL string, opts *Options, f func(*http.Request)) *Iterator[T] {
+	return &Iterator[T]{ctx: ctx, c: c, url: rawURL, opts: opts.withDefaults(), f: f}
+}
+
+// Next advances to the next item, requesting the next page when needed.
+// It returns false when there are no more items or an error occurred.
+func (it *Iterator[T]) Next() bool {
+	for len(it.items) == 0 {
+		if it.err != nil || it.url == "" {
+			return false
+		}
+		if it.err = it.fetch(); it.err != nil {
+			return false
+		}
+	}
+
+	it.item, it.items = it.items[0], it.items[1:]
+	return true
+}
+
+// Item returns the current item.
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Page returns the last page requested, or nil before the first call to Next.
+func (it *Iterator[T]) Page() *Page[T] {
+	return it.page
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+func (it *Iterator[T]) fetch() error {
+	resp, err := it.c.Request(it.ctx, http.MethodGet, it.url, nil, it.f)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("pagination: unexpected status: %s", resp.Status)
+	}
+
+	page := new(Page[T])
+	if err = it.c.Decode(resp.Body, page); err != nil {
+		return err
+	}
+
+	next, err := it.nextURL(resp, page)
+	if err != nil {
+		return err
+	}
+	if next == it.url {
+		// The server links the page to itself, stop instead of looping.
+		next = ""
+	}
+
+	it.page, it.items, it.url = page, page.Items, next
+	return nil
+}
+
+func (it *Iterator[T]) nextURL(resp *http.Response, page *Page[T]) (string, error) {
+	base, err := url.Parse(it.url)
+	if err != nil {
+		return "", err
+	}
+
+	if link := FindLink(ParseLinks(resp.Header.Values("Link")), RelNext); link != "" {
+		ref, err := url.Parse(link)
+		if err != nil {
+			return "", err
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+
+	if page.Next == "" {
+		return "", nil
+	}
+
+	query := base.Query()
+	query.Del(it.opts.OffsetParam)
+	query.Set(it.opts.CursorParam, page.Next)
+	base.RawQuery = query.Encode()
+	return base.String(), nil
+}
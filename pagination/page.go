@@ -0,0 +1,139 @@
+package pagination
+
+import (
+	"encoding/xml"
+	"net/url"
+	"strings"
+
+	"github.com/gromey/proto-rest/server"
+)
+
+// Link relation types of the links of a Page.
+const (
+	RelFirst = "first"
+	RelPrev  = "prev"
+	RelNext  = "next"
+)
+
+// Link represents a web link (RFC 8288).
+type Link = server.Link
+
+// Linker is implemented by response values with links, which server.WriteResponse writes to the Link header.
+type Linker = server.Linker
+
+// Page represents a page of items with the cursors of the adjacent pages.
+type Page[T any] struct {
+	XMLName xml.Name `json:"-" xml:"page" msgpack:"-" form:"-"`
+	Items   []T      `json:"items" xml:"item" msgpack:"items" form:"-"`
+	Next    string   `json:"next,omitempty" xml:"next,omitempty" msgpack:"next,omitempty" form:"next,omitempty"`
+	Prev    string   `json:"prev,omitempty" xml:"prev,omitempty" msgpack:"prev,omitempty" form:"prev,omitempty"`
+	Total   int      `json:"total,omitempty" xml:"total,omitempty" msgpack:"total,omitempty" form:"total,omitempty"`
+
+	links []Link
+}
+
+// NewPage returns a new Page of items. An empty next or prev cursor means there is no such page.
+// Items is never nil, so it is encoded as an empty list.
+func NewPage[T any](items []T, next, prev string) *Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return &Page[T]{Items: items, Next: next, Prev: prev}
+}
+
+// WithLinks sets the first, prev and next links of the page, built from the request URL u by replacing
+// the cursor parameter and removing the offset parameter. It returns p.
+func (p *Page[T]) WithLinks(u *url.URL, opts *Options) *Page[T] {
+	opts = opts.withDefaults()
+
+	link := func(rel, cursor string) Link {
+		ref := *u
+		query := ref.Query()
+		query.Del(opts.OffsetParam)
+		query.Del(opts.CursorParam)
+		if cursor != "" {
+			query.Set(opts.CursorParam, cursor)
+		}
+		ref.RawQuery = query.Encode()
+		return Link{URL: ref.String(), Rel: rel}
+	}
+
+	p.links = []Link{link(RelFirst, "")}
+	if p.Prev != "" {
+		p.links = append(p.links, link(RelPrev, p.Prev))
+	}
+	if p.Next != "" {
+		p.links = append(p.links, link(RelNext, p.Next))
+	}
+	return p
+}
+
+// Links returns the links set by WithLinks.
+func (p *Page[T]) Links() []Link {
+	return p.links
+}
+
+// ParseLinks parses Link header values. Links with several relation types are returned once per type.
+// Malformed links are skipped.
+func ParseLinks(values []string) []Link {
+	var links []Link
+	for _, value := range values {
+		for value != "" {
+			value = strings.TrimLeft(value, " \t,")
+			if !strings.HasPrefix(value, "<") {
+				break
+			}
+			end := strings.IndexByte(value, '>')
+			if end < 0 {
+				break
+			}
+			target := value[1:end]
+			value = value[end+1:]
+
+			// The parameters end at the next comma outside a quoted string.
+			var params string
+			params, value = splitParams(value)
+
+			for _, param := range strings.Split(params, ";") {
+				name, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(val), `"`)) {
+					links = append(links, Link{URL: target, Rel: strings.ToLower(rel)})
+				}
+				break
+			}
+		}
+	}
+	return links
+}
+
+func splitParams(s string) (params, rest string) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case ',':
+			if !quoted {
+				return s[:i], s[i+1:]
+			}
+		}
+	}
+	return s, ""
+}
+
+// FindLink returns the URL of the first link with the relation type rel, or an empty string.
+func FindLink(links []Link, rel string) string {
+	for _, l := range links {
+		if l.Rel == rel {
+			return l.URL
+		}
+	}
+	return ""
+}
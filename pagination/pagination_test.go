@@ -0,0 +1,209 @@
+package pagination_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/gromey/proto-rest/client"
+	"github.com/gromey/proto-rest/coder"
+	"github.com/gromey/proto-rest/errors"
+	"github.com/gromey/proto-rest/logger"
+	"github.com/gromey/proto-rest/pagination"
+	"github.com/gromey/proto-rest/server"
+)
+
+func init() {
+	logger.SetLogger(logger.New(nil))
+}
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	opts := &pagination.Options{DefaultLimit: 10, MaxLimit: 50, MaxOffset: 1000}
+
+	tests := []struct {
+		name  string
+		query string
+		exp   pagination.Params
+		code  int
+	}{
+		{name: "defaults", query: "", exp: pagination.Params{Limit: 10}},
+		{name: "cursor", query: "cursor=abc&limit=5", exp: pagination.Params{Cursor: "abc", Limit: 5}},
+		{name: "offset", query: "offset=40&limit=20", exp: pagination.Params{Offset: 40, Limit: 20}},
+		{name: "limit above max", query: "limit=500", exp: pagination.Params{Limit: 50}},
+		{name: "zero limit", query: "limit=0", code: http.StatusBadRequest},
+		{name: "malformed limit", query: "limit=ten", code: http.StatusBadRequest},
+		{name: "negative offset", query: "offset=-1", code: http.StatusBadRequest},
+		{name: "offset above max", query: "offset=1001", code: http.StatusBadRequest},
+		{name: "cursor and offset", query: "cursor=abc&offset=10", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/items?"+tt.query, nil)
+			p, err := pagination.Parse(r, opts)
+			if tt.code != 0 {
+				e, ok := err.(errors.Error)
+				equal(t, true, ok)
+				equal(t, tt.code, e.Code())
+				return
+			}
+			equal(t, nil, err)
+			equal(t, tt.exp, p)
+		})
+	}
+}
+
+type position struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestCursors(t *testing.T) {
+	signed := pagination.NewCursors([]byte("secret"))
+	cursor, err := signed.Encode(position{ID: 42, Name: "x"})
+	equal(t, nil, err)
+
+	got := position{}
+	equal(t, nil, signed.Decode(cursor, &got))
+	equal(t, position{ID: 42, Name: "x"}, got)
+
+	// A cursor signed with another key, a tampered cursor and an unsigned cursor are rejected.
+	other, err := pagination.NewCursors([]byte("other")).Encode(position{ID: 42, Name: "x"})
+	equal(t, nil, err)
+	forged, err := pagination.NewCursors(nil).Encode(position{ID: 43, Name: "x"})
+	equal(t, nil, err)
+
+	for _, c := range []string{other, forged, forged + cursor[len(forged):], "", "!!!"} {
+		equal(t, pagination.ErrInvalidCursor, signed.Decode(c, &got))
+	}
+
+	// Unsigned cursors are only encoded.
+	got = position{}
+	equal(t, nil, pagination.NewCursors(nil).Decode(forged, &got))
+	equal(t, position{ID: 43, Name: "x"}, got)
+}
+
+func TestParseLinks(t *testing.T) {
+	links := pagination.ParseLinks([]string{
+		`<https://example.com/?cursor=b>; rel="next", </?c=a,b>; title="a, b"; rel="prev first"`,
+		`<https://example.com/last>; rel=last`,
+		`malformed`,
+	})
+
+	equal(t, []pagination.Link{
+		{URL: "https://example.com/?cursor=b", Rel: "next"},
+		{URL: "/?c=a,b", Rel: "prev"},
+		{URL: "/?c=a,b", Rel: "first"},
+		{URL: "https://example.com/last", Rel: "last"},
+	}, links)
+	equal(t, "/?c=a,b", pagination.FindLink(links, pagination.RelPrev))
+	equal(t, "", pagination.FindLink(links, "self"))
+}
+
+type item struct {
+	ID int `json:"id"`
+}
+
+// newListServer returns a server that lists the items 1 to n with cursors holding the last ID of a page.
+func newListServer(t *testing.T, n int, links bool) *httptest.Server {
+	cdr := coder.NewJSONCoder(nil)
+	srv := server.New(cdr)
+	cursors := pagination.NewCursors([]byte("secret"))
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := pagination.Parse(r, &pagination.Options{DefaultLimit: 3})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var after int
+		if p.Cursor != "" {
+			if err = cursors.Decode(p.Cursor, &after); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var items []item
+		for id := after + 1; id <= n && len(items) < p.Limit; id++ {
+			items = append(items, item{ID: id})
+		}
+
+		var next string
+		if len(items) != 0 && items[len(items)-1].ID < n {
+			next, err = cursors.Encode(items[len(items)-1].ID)
+			equal(t, nil, err)
+		}
+
+		page := pagination.NewPage(items, next, "")
+		if links {
+			page.WithLinks(r.URL, nil)
+		}
+		srv.WriteResponse(w, http.StatusOK, page)
+	}))
+}
+
+func TestPage_WriteResponse(t *testing.T) {
+	ts := newListServer(t, 5, true)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/items?limit=2&filter=a")
+	equal(t, nil, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	page := new(pagination.Page[item])
+	equal(t, nil, json.NewDecoder(resp.Body).Decode(page))
+	equal(t, []item{{ID: 1}, {ID: 2}}, page.Items)
+
+	links := pagination.ParseLinks(resp.Header.Values("Link"))
+	equal(t, 2, len(links))
+	equal(t, "/items?filter=a&limit=2", pagination.FindLink(links, pagination.RelFirst))
+
+	next, err := url.Parse(pagination.FindLink(links, pagination.RelNext))
+	equal(t, nil, err)
+	equal(t, page.Next, next.Query().Get("cursor"))
+	equal(t, "2", next.Query().Get("limit"))
+}
+
+func TestIterator(t *testing.T) {
+	for _, links := range []bool{true, false} {
+		t.Run("links "+strconv.FormatBool(links), func(t *testing.T) {
+			ts := newListServer(t, 7, links)
+			defer ts.Close()
+
+			c := client.New(coder.NewJSONCoder(nil), ts.Client())
+			it := pagination.NewIterator[item](context.Background(), c, ts.URL+"/items", nil, nil)
+
+			var ids []int
+			for it.Next() {
+				ids = append(ids, it.Item().ID)
+			}
+			equal(t, nil, it.Err())
+			equal(t, []int{1, 2, 3, 4, 5, 6, 7}, ids)
+			equal(t, "", it.Page().Next)
+		})
+	}
+
+	t.Run("error status", func(t *testing.T) {
+		ts := newListServer(t, 7, true)
+		defer ts.Close()
+
+		c := client.New(coder.NewJSONCoder(nil), ts.Client())
+		it := pagination.NewIterator[item](context.Background(), c, ts.URL+"/items?cursor=forged", nil, nil)
+
+		equal(t, false, it.Next())
+		equal(t, "pagination: unexpected status: 400 Bad Request", it.Err().Error())
+	})
+}
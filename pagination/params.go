@@ -0,0 +1,89 @@
+package pagination
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gromey/proto-rest/errors"
+)
+
+// Options represents options for configuring Parse and the links of a Page.
+type Options struct {
+	DefaultLimit int    // The limit when the query has none. Defaults to 20.
+	MaxLimit     int    // Larger limits are lowered to it. Defaults to 100.
+	MaxOffset    int    // Larger offsets are rejected. Zero means no limit.
+	CursorParam  string // The query parameter of the cursor. Defaults to cursor.
+	OffsetParam  string // The query parameter of the offset. Defaults to offset.
+	LimitParam   string // The query parameter of the limit. Defaults to limit.
+}
+
+func (o *Options) withDefaults() *Options {
+	opts := new(Options)
+	if o != nil {
+		*opts = *o
+	}
+	if opts.DefaultLimit <= 0 {
+		opts.DefaultLimit = 20
+	}
+	if opts.MaxLimit <= 0 {
+		opts.MaxLimit = 100
+	}
+	if opts.DefaultLimit > opts.MaxLimit {
+		opts.DefaultLimit = opts.MaxLimit
+	}
+	if opts.CursorParam == "" {
+		opts.CursorParam = "cursor"
+	}
+	if opts.OffsetParam == "" {
+		opts.OffsetParam = "offset"
+	}
+	if opts.LimitParam == "" {
+		opts.LimitParam = "limit"
+	}
+	return opts
+}
+
+// Params represents the pagination parameters of a request.
+type Params struct {
+	Cursor string // The opaque cursor, empty for the first page.
+	Offset int    // The number of items to skip.
+	Limit  int    // The maximum number of items in the page, between 1 and MaxLimit.
+}
+
+// Parse parses the cursor, offset and limit query parameters of r. A malformed or negative offset or limit,
+// an offset larger than MaxOffset, or both a cursor and an offset return an errors.Error with status 400.
+func Parse(r *http.Request, opts *Options) (Params, error) {
+	opts = opts.withDefaults()
+	query := r.URL.Query()
+
+	p := Params{Cursor: query.Get(opts.CursorParam), Limit: opts.DefaultLimit}
+
+	if s := query.Get(opts.LimitParam); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			return Params{}, invalidParam(opts.LimitParam)
+		}
+		if limit > opts.MaxLimit {
+			limit = opts.MaxLimit
+		}
+		p.Limit = limit
+	}
+
+	if s := query.Get(opts.OffsetParam); s != "" {
+		offset, err := strconv.Atoi(s)
+		if err != nil || offset < 0 || opts.MaxOffset > 0 && offset > opts.MaxOffset {
+			return Params{}, invalidParam(opts.OffsetParam)
+		}
+		if p.Cursor != "" {
+			return Params{}, errors.New(http.StatusBadRequest, fmt.Sprintf("%s and %s can't be used together", opts.CursorParam, opts.OffsetParam))
+		}
+		p.Offset = offset
+	}
+
+	return p, nil
+}
+
+func invalidParam(name string) errors.Error {
+	return errors.New(http.StatusBadRequest, fmt.Sprintf("invalid %s parameter", name))
+}
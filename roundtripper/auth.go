@@ -0,0 +1,268 @@
+package roundtripper
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gromey/proto-rest/utils"
+)
+
+// Token represents a credential used to authorize requests.
+type Token struct {
+	AccessToken string    // The token that authorizes the requests.
+	TokenType   string    // The type of the token. Defaults to "Bearer".
+	Expiry      time.Time // The time the token expires. Zero means the token never expires.
+}
+
+// Valid reports whether the token is set and does not expire within leeway.
+func (t *Token) Valid(leeway time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(leeway).Before(t.Expiry)
+}
+
+// A TokenSource returns tokens to authorize requests.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+type staticToken string
+
+// StaticToken returns a TokenSource that always returns the same bearer token.
+func StaticToken(token string) TokenSource {
+	return staticToken(token)
+}
+
+// Token returns the static token.
+func (s staticToken) Token(context.Context) (*Token, error) {
+	return &Token{AccessToken: string(s)}, nil
+}
+
+// Bearer sets the Authorization header of each request to the token returned by ts.
+func Bearer(ts TokenSource) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Func(func(r *http.Request) (*http.Response, error) {
+			token, err := ts.Token(r.Context())
+			if err != nil {
+				return nil, err
+			}
+
+			tokenType := token.TokenType
+			if tokenType == "" {
+				tokenType = "Bearer"
+			}
+
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+// ClientCredentialsConfig represents options for the OAuth2 client credentials flow (RFC 6749, section 4.4).
+type ClientCredentialsConfig struct {
+	TokenURL     string        // The token endpoint of the authorization server.
+	ClientID     string        // The client identifier.
+	ClientSecret string        // The client secret.
+	Scopes       []string      // The requested scopes.
+	Client       *http.Client  // The client used to fetch tokens. Defaults to http.DefaultClient.
+	EarlyExpiry  time.Duration // How long before expiry the token is refreshed. Defaults to 10 seconds.
+}
+
+type clientCredentials struct {
+	cfg *ClientCredentialsConfig
+
+	mu       sync.Mutex
+	token    *Token
+	inflight *tokenCall
+}
+
+type tokenCall struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// ClientCredentials returns a TokenSource that fetches tokens with the OAuth2 client credentials flow.
+// Tokens are cached and refreshed ahead of expiry. Concurrent callers share a single refresh.
+func ClientCredentials(cfg *ClientCredentialsConfig) TokenSource {
+	return &clientCredentials{cfg: cfg}
+}
+
+// Token returns a cached token or fetches a new one if the cached token is about to expire.
+func (c *clientCredentials) Token(ctx context.Context) (*Token, error) {
+	leeway := c.cfg.EarlyExpiry
+	if leeway == 0 {
+		leeway = 10 * time.Second
+	}
+
+	c.mu.Lock()
+	if c.token.Valid(leeway) {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+
+	call := c.inflight
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		c.inflight = call
+		go c.refresh(call)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// refresh fetches a new token. It is not bound to the context of any single caller,
+// so one caller giving up does not fail the refresh for the others.
+func (c *clientCredentials) refresh(call *tokenCall) {
+	call.token, call.err = c.fetch()
+
+	c.mu.Lock()
+	if call.err == nil {
+		c.token = call.token
+	}
+	c.inflight = nil
+	c.mu.Unlock()
+
+	close(call.done)
+}
+
+func (c *clientCredentials) fetch() (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.cfg.Scopes, " "))
+	}
+
+	request, err := http.NewRequest(http.MethodPost, c.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+
+	client := c.cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.Closer(resp.Body)
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth2: cannot fetch token: %s: %s", resp.Status, body)
+	}
+
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err = json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("oauth2: cannot parse token response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return nil, fmt.Errorf("oauth2: server response missing access_token")
+	}
+
+	token := &Token{AccessToken: tr.AccessToken, TokenType: tr.TokenType}
+	if strings.EqualFold(token.TokenType, "bearer") {
+		token.TokenType = "Bearer"
+	}
+	if tr.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+// HMACOptions represents options for configuring the HMAC request signing round tripper.
+type HMACOptions struct {
+	KeyID  string           // The identifier of the key, sent along with the signature.
+	Secret []byte           // The shared secret.
+	Hash   func() hash.Hash // The hash function. Defaults to sha256.New.
+}
+
+// HMAC signs each request with a shared secret following draft-cavage-http-signatures-12.
+// The signing string covers the (request-target), date and digest headers. The Date header is set if missing,
+// the Digest header is always set to the SHA-256 digest of the body, and the signature is sent in the Authorization
+// header: Signature keyId="...",algorithm="hs2019",headers="(request-target) date digest",signature="...".
+func HMAC(opts *HMACOptions) func(http.RoundTripper) http.RoundTripper {
+	hashFunc := opts.Hash
+	if hashFunc == nil {
+		hashFunc = sha256.New
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Func(func(r *http.Request) (*http.Response, error) {
+			var body []byte
+			if r.Body != nil && r.Body != http.NoBody {
+				var err error
+				if body, err = io.ReadAll(r.Body); err != nil {
+					return nil, err
+				}
+				utils.Closer(r.Body)
+			}
+
+			r = r.Clone(r.Context())
+			if body != nil {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				r.GetBody = func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(body)), nil
+				}
+			}
+
+			if r.Header.Get("Date") == "" {
+				r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+			}
+
+			sum := sha256.Sum256(body)
+			digest := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+			r.Header.Set("Digest", digest)
+
+			mac := hmac.New(hashFunc, opts.Secret)
+			mac.Write([]byte(signingString(r)))
+			signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+			r.Header.Set("Authorization", fmt.Sprintf(`Signature keyId=%q,algorithm="hs2019",headers="(request-target) date digest",signature=%q`, opts.KeyID, signature))
+
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+// signingString returns the string HMAC signs for the (request-target), date and digest headers.
+func signingString(r *http.Request) string {
+	return "(request-target): " + strings.ToLower(r.Method) + " " + r.URL.RequestURI() + "\n" +
+		"date: " + r.Header.Get("Date") + "\n" +
+		"digest: " + r.Header.Get("Digest")
+}
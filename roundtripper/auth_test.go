@@ -0,0 +1,121 @@
+package roundtripper_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gromey/proto-rest/logger"
+	"github.com/gromey/proto-rest/roundtripper"
+)
+
+func init() {
+	logger.SetLogger(logger.New(nil))
+}
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+func TestBearer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equal(t, "Bearer token", r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	clt := &http.Client{Transport: roundtripper.Sequencer(http.DefaultTransport, roundtripper.Bearer(roundtripper.StaticToken("token")))}
+
+	resp, err := clt.Get(srv.URL)
+	equal(t, nil, err)
+	defer func() { _ = resp.Body.Close() }()
+	equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestClientCredentials(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		id, secret, _ := r.BasicAuth()
+		equal(t, "id", id)
+		equal(t, "secret", secret)
+		equal(t, nil, r.ParseForm())
+		equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		equal(t, "read write", r.PostForm.Get("scope"))
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "token", "token_type": "bearer", "expires_in": 3600})
+	}))
+	defer srv.Close()
+
+	ts := roundtripper.ClientCredentials(&roundtripper.ClientCredentialsConfig{
+		TokenURL:     srv.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+		Client:       srv.Client(),
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := ts.Token(context.Background())
+			equal(t, nil, err)
+			equal(t, "token", token.AccessToken)
+			equal(t, "Bearer", token.TokenType)
+		}()
+	}
+	wg.Wait()
+
+	equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHMAC(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		equal(t, nil, err)
+		equal(t, "body", string(body))
+
+		sum := sha256.Sum256(body)
+		digest := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+		equal(t, digest, r.Header.Get("Digest"))
+
+		date := r.Header.Get("Date")
+		_, err = http.ParseTime(date)
+		equal(t, nil, err)
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte("(request-target): post /path?q=1\ndate: " + date + "\ndigest: " + digest))
+		signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+		exp := fmt.Sprintf(`Signature keyId="key",algorithm="hs2019",headers="(request-target) date digest",signature=%q`, signature)
+		equal(t, exp, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	clt := &http.Client{Transport: roundtripper.Sequencer(http.DefaultTransport, roundtripper.HMAC(&roundtripper.HMACOptions{KeyID: "key", Secret: []byte("secret")}))}
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/path?q=1", strings.NewReader("body"))
+	equal(t, nil, err)
+	// The digest always matches the body that is sent.
+	req.Header.Set("Digest", "SHA-256=stale")
+
+	resp, err := clt.Do(req)
+	equal(t, nil, err)
+	defer func() { _ = resp.Body.Close() }()
+	equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,404 @@
+package roundtripper
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gromey/proto-rest/logger"
+	"github.com/gromey/proto-rest/utils"
+)
+
+// CacheEntry represents a stored response.
+type CacheEntry struct {
+	StatusCode   int         // The status code of the response.
+	Header       http.Header // The headers of the response.
+	Body         []byte      // The body of the response.
+	VaryHeader   http.Header // The request headers named by the Vary response header.
+	RequestTime  time.Time   // The time the request was sent.
+	ResponseTime time.Time   // The time the response was received.
+}
+
+// A CacheStore stores responses for the Cache round tripper. It must be safe for concurrent use.
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+type lruStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewLRUStore returns an in-memory CacheStore that evicts the least recently used entry
+// when it holds more than maxEntries entries. Zero means no limit.
+func NewLRUStore(maxEntries int) CacheStore {
+	return &lruStore{maxEntries: maxEntries, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Get returns the entry stored under key and marks it as recently used.
+func (s *lruStore) Get(key string) (*CacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.ll.MoveToFront(el)
+		return el.Value.(*lruItem).entry, true
+	}
+	return nil, false
+}
+
+// Set stores the entry under key, evicting the least recently used entry if the store is full.
+func (s *lruStore) Set(key string, entry *CacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.ll.MoveToFront(el)
+		el.Value.(*lruItem).entry = entry
+		return
+	}
+	s.items[key] = s.ll.PushFront(&lruItem{key: key, entry: entry})
+	if s.maxEntries > 0 && s.ll.Len() > s.maxEntries {
+		el := s.ll.Back()
+		s.ll.Remove(el)
+		delete(s.items, el.Value.(*lruItem).key)
+	}
+}
+
+// Delete removes the entry stored under key.
+func (s *lruStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.ll.Remove(el)
+		delete(s.items, key)
+	}
+}
+
+// CacheStatusHeader is set on responses passed through the Cache round tripper to HIT, MISS, REVALIDATED or STALE.
+const CacheStatusHeader = "X-Cache"
+
+// CacheOptions represents options for configuring the Cache round tripper.
+type CacheOptions struct {
+	Store        CacheStore    // Where responses are stored. Defaults to NewLRUStore(1000).
+	Shared       bool          // Behave as a shared cache: skip private and authorized responses and honor s-maxage.
+	StaleIfError time.Duration // How long a stale response may be served if revalidation fails, unless the response sets stale-if-error.
+	MaxEntrySize int64         // Responses with larger bodies are passed through without caching. Defaults to 1 MiB.
+}
+
+// Cache caches responses to GET and HEAD requests according to RFC 9111.
+// It honors max-age, s-maxage, Expires, no-store, no-cache, private and Vary, revalidates stale responses
+// with If-None-Match and If-Modified-Since, and serves stale responses on errors within stale-if-error.
+// Successful unsafe requests invalidate the cached response for their URL.
+// Cache hits and misses are logged at logLevel.
+func Cache(logLevel logger.Level, opts *CacheOptions) func(http.RoundTripper) http.RoundTripper {
+	if opts == nil {
+		opts = new(CacheOptions)
+	}
+	store := opts.Store
+	if store == nil {
+		store = NewLRUStore(1000)
+	}
+	if opts.MaxEntrySize <= 0 {
+		o := *opts
+		o.MaxEntrySize = 1 << 20
+		opts = &o
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		c := &cache{next: next, cacheStore: store, opts: opts, logLevel: logLevel}
+		return Func(c.roundTrip)
+	}
+}
+
+type cache struct {
+	next       http.RoundTripper
+	cacheStore CacheStore
+	opts       *CacheOptions
+	logLevel   logger.Level
+}
+
+func (c *cache) log(r *http.Request, status string) {
+	if logger.InLevel(c.logLevel) {
+		c.logLevel.Printf()("Cache %s: %s %s", status, r.Method, r.URL)
+	}
+}
+
+func (c *cache) roundTrip(r *http.Request) (*http.Response, error) {
+	key := r.Method + " " + r.URL.String()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		resp, err := c.next.RoundTrip(r)
+		if err == nil && resp.StatusCode < 400 {
+			c.cacheStore.Delete(http.MethodGet + " " + r.URL.String())
+			c.cacheStore.Delete(http.MethodHead + " " + r.URL.String())
+		}
+		return resp, err
+	}
+
+	// Conditional requests made by the caller are passed through, the caller handles the 304.
+	if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+		return c.next.RoundTrip(r)
+	}
+
+	reqCC := utils.ParseCacheControl(r.Header.Values("Cache-Control"))
+	if reqCC.Has("no-store") {
+		return c.next.RoundTrip(r)
+	}
+
+	entry, ok := c.cacheStore.Get(key)
+	if ok && !varyMatches(entry, r) {
+		entry, ok = nil, false
+	}
+
+	if !ok {
+		c.log(r, "MISS")
+		return c.fetch(r, key)
+	}
+
+	if !reqCC.Has("no-cache") && c.isFresh(entry, reqCC) {
+		c.log(r, "HIT")
+		return entry.response(r, "HIT"), nil
+	}
+
+	return c.revalidate(r, key, entry)
+}
+
+// fetch sends the request and stores the response if it is cacheable.
+func (c *cache) fetch(r *http.Request, key string) (*http.Response, error) {
+	requestTime := time.Now()
+	resp, err := c.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	return c.store(r, key, resp, requestTime)
+}
+
+// store stores the response if it is cacheable and marks it as a cache miss.
+func (c *cache) store(r *http.Request, key string, resp *http.Response, requestTime time.Time) (*http.Response, error) {
+	resp.Header.Set(CacheStatusHeader, "MISS")
+
+	if !c.isCacheable(r, resp) || resp.ContentLength > c.opts.MaxEntrySize {
+		c.cacheStore.Delete(key)
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.opts.MaxEntrySize+1))
+	if err != nil {
+		utils.Closer(resp.Body)
+		return nil, err
+	}
+	if int64(len(body)) > c.opts.MaxEntrySize {
+		// Pass the response through with the part that was already read.
+		c.cacheStore.Delete(key)
+		resp.Body = &struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	utils.Closer(resp.Body)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry := &CacheEntry{
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		VaryHeader:   make(http.Header),
+		RequestTime:  requestTime,
+		ResponseTime: time.Now(),
+	}
+	entry.Header.Del(CacheStatusHeader)
+	for _, name := range varyNames(resp.Header) {
+		entry.VaryHeader[name] = r.Header.Values(name)
+	}
+	c.cacheStore.Set(key, entry)
+
+	return resp, nil
+}
+
+// revalidate sends a conditional request for a stale entry, or a plain one if the entry has no validators.
+func (c *cache) revalidate(r *http.Request, key string, entry *CacheEntry) (*http.Response, error) {
+	cr := r.Clone(r.Context())
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		cr.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+		cr.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	requestTime := time.Now()
+	resp, err := c.next.RoundTrip(cr)
+	if c.canServeStale(entry, resp, err) {
+		if resp != nil {
+			utils.Closer(resp.Body)
+		}
+		c.log(r, "STALE")
+		return entry.response(r, "STALE"), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusNotModified {
+		c.log(r, "MISS")
+		return c.store(r, key, resp, requestTime)
+	}
+	utils.Closer(resp.Body)
+
+	updated := *entry
+	updated.Header = entry.Header.Clone()
+	for name, values := range resp.Header {
+		if name != "Content-Length" {
+			updated.Header[name] = values
+		}
+	}
+	updated.RequestTime = requestTime
+	updated.ResponseTime = time.Now()
+	c.cacheStore.Set(key, &updated)
+
+	c.log(r, "REVALIDATED")
+	return updated.response(r, "REVALIDATED"), nil
+}
+
+func (c *cache) canServeStale(entry *CacheEntry, resp *http.Response, err error) bool {
+	if err == nil && resp.StatusCode < 500 {
+		return false
+	}
+	limit := c.opts.StaleIfError
+	if d, ok := utils.ParseCacheControl(entry.Header.Values("Cache-Control")).Duration("stale-if-error"); ok {
+		limit = d
+	}
+	return entry.age() <= c.freshnessLifetime(entry)+limit && limit > 0
+}
+
+func (c *cache) isCacheable(r *http.Request, resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+	default:
+		return false
+	}
+
+	cc := utils.ParseCacheControl(resp.Header.Values("Cache-Control"))
+	if cc.Has("no-store") || (c.opts.Shared && cc.Has("private")) {
+		return false
+	}
+	// A shared cache must not store responses to authorized requests unless they allow it (RFC 9111, section 3.5).
+	if _, hasSMaxAge := cc.Duration("s-maxage"); c.opts.Shared && r.Header.Get("Authorization") != "" &&
+		!cc.Has("public") && !cc.Has("must-revalidate") && !hasSMaxAge {
+		return false
+	}
+	for _, name := range varyNames(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	_, hasMaxAge := cc.Duration("max-age")
+	return hasMaxAge || cc.Has("no-cache") || resp.Header.Get("Expires") != "" ||
+		resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+func (c *cache) freshnessLifetime(entry *CacheEntry) time.Duration {
+	cc := utils.ParseCacheControl(entry.Header.Values("Cache-Control"))
+	if cc.Has("no-cache") {
+		return 0
+	}
+	if c.opts.Shared {
+		if d, ok := cc.Duration("s-maxage"); ok {
+			return d
+		}
+	}
+	if d, ok := cc.Duration("max-age"); ok {
+		return d
+	}
+	if expires, err := http.ParseTime(entry.Header.Get("Expires")); err == nil {
+		date, err := http.ParseTime(entry.Header.Get("Date"))
+		if err != nil {
+			date = entry.ResponseTime
+		}
+		return expires.Sub(date)
+	}
+	return 0
+}
+
+func (c *cache) isFresh(entry *CacheEntry, reqCC utils.CacheControl) bool {
+	lifetime := c.freshnessLifetime(entry)
+	if d, ok := reqCC.Duration("max-age"); ok && d < lifetime {
+		lifetime = d
+	}
+	age := entry.age()
+	if d, ok := reqCC.Duration("min-fresh"); ok {
+		age += d
+	}
+	return age < lifetime
+}
+
+// age returns the current age of the entry (RFC 9111, section 4.2.3).
+func (e *CacheEntry) age() time.Duration {
+	var ageValue time.Duration
+	if seconds, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+	apparentAge := time.Duration(0)
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil && e.ResponseTime.After(date) {
+		apparentAge = e.ResponseTime.Sub(date)
+	}
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	if apparentAge > correctedAge {
+		correctedAge = apparentAge
+	}
+	return correctedAge + time.Since(e.ResponseTime)
+}
+
+func (e *CacheEntry) response(r *http.Request, status string) *http.Response {
+	resp := &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       r,
+	}
+	resp.Header.Set("Age", strconv.FormatInt(int64(e.age()/time.Second), 10))
+	resp.Header.Set(CacheStatusHeader, status)
+	return resp
+}
+
+func varyMatches(entry *CacheEntry, r *http.Request) bool {
+	for name, values := range entry.VaryHeader {
+		if strings.Join(values, ",") != strings.Join(r.Header.Values(name), ",") {
+			return false
+		}
+	}
+	return true
+}
+
+func varyNames(h http.Header) []string {
+	var names []string
+	for _, value := range h.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,137 @@
+package roundtripper_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gromey/proto-rest/logger"
+	"github.com/gromey/proto-rest/roundtripper"
+)
+
+func TestCache(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/chunked":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.(http.Flusher).Flush()
+		}
+		_, _ = io.WriteString(w, "body")
+	}))
+	defer srv.Close()
+
+	var tests = []struct {
+		name      string
+		opts      *roundtripper.CacheOptions
+		header    map[string]string
+		path      string
+		expStatus []string
+		expCalls  int
+	}{
+		{
+			name:      "fresh response",
+			path:      "/fresh",
+			expStatus: []string{"MISS", "HIT", "HIT"},
+			expCalls:  1,
+		},
+		{
+			name:      "revalidated response",
+			path:      "/etag",
+			expStatus: []string{"MISS", "REVALIDATED", "REVALIDATED"},
+			expCalls:  3,
+		},
+		{
+			name:      "no-store response",
+			path:      "/no-store",
+			expStatus: []string{"MISS", "MISS"},
+			expCalls:  2,
+		},
+		{
+			name:      "response larger than MaxEntrySize",
+			opts:      &roundtripper.CacheOptions{MaxEntrySize: 3},
+			path:      "/fresh",
+			expStatus: []string{"MISS", "MISS"},
+			expCalls:  2,
+		},
+		{
+			name:      "chunked response larger than MaxEntrySize",
+			opts:      &roundtripper.CacheOptions{MaxEntrySize: 3},
+			path:      "/chunked",
+			expStatus: []string{"MISS", "MISS"},
+			expCalls:  2,
+		},
+		{
+			name:      "chunked response",
+			path:      "/chunked",
+			expStatus: []string{"MISS", "HIT"},
+			expCalls:  1,
+		},
+		{
+			name:      "authorized request in a shared cache",
+			opts:      &roundtripper.CacheOptions{Shared: true},
+			header:    map[string]string{"Authorization": "Bearer token"},
+			path:      "/fresh",
+			expStatus: []string{"MISS", "MISS"},
+			expCalls:  2,
+		},
+		{
+			name:      "authorized request for a public response in a shared cache",
+			opts:      &roundtripper.CacheOptions{Shared: true},
+			header:    map[string]string{"Authorization": "Bearer token"},
+			path:      "/public",
+			expStatus: []string{"MISS", "HIT"},
+			expCalls:  1,
+		},
+		{
+			name:      "authorized request in a private cache",
+			header:    map[string]string{"Authorization": "Bearer token"},
+			path:      "/fresh",
+			expStatus: []string{"MISS", "HIT"},
+			expCalls:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls = 0
+			clt := &http.Client{Transport: roundtripper.Sequencer(srv.Client().Transport, roundtripper.Cache(logger.LevelDebug, test.opts))}
+
+			for _, status := range test.expStatus {
+				req, err := http.NewRequest(http.MethodGet, srv.URL+test.path, nil)
+				equal(t, nil, err)
+				for k, v := range test.header {
+					req.Header.Set(k, v)
+				}
+
+				resp, err := clt.Do(req)
+				equal(t, nil, err)
+
+				b, err := io.ReadAll(resp.Body)
+				equal(t, nil, err)
+				_ = resp.Body.Close()
+
+				equal(t, http.StatusOK, resp.StatusCode)
+				equal(t, "body", string(b))
+				equal(t, status, resp.Header.Get(roundtripper.CacheStatusHeader))
+			}
+
+			equal(t, test.expCalls, calls)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package roundtripper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"github.com/gromey/proto-rest/utils"
+)
+
+// CompressRequest gzips request bodies that are at least minSize bytes long and sets Content-Encoding: gzip.
+// Requests that already have a Content-Encoding are sent as is.
+// The server must accept compressed bodies, e.g. with middleware.Decompress.
+func CompressRequest(minSize int64, level int) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Func(func(r *http.Request) (*http.Response, error) {
+			// A zero ContentLength with a body means the length is unknown.
+			if r.Body == nil || r.Body == http.NoBody || r.Header.Get("Content-Encoding") != "" ||
+				(r.ContentLength > 0 && r.ContentLength < minSize) {
+				return next.RoundTrip(r)
+			}
+
+			body, err := io.ReadAll(r.Body)
+			utils.Closer(r.Body)
+			if err != nil {
+				return nil, err
+			}
+
+			r = r.Clone(r.Context())
+
+			if int64(len(body)) < minSize {
+				setBody(r, body)
+				return next.RoundTrip(r)
+			}
+
+			buf := new(bytes.Buffer)
+			zw, err := gzip.NewWriterLevel(buf, level)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = zw.Write(body); err != nil {
+				return nil, err
+			}
+			if err = zw.Close(); err != nil {
+				return nil, err
+			}
+
+			setBody(r, buf.Bytes())
+			r.Header.Set("Content-Encoding", "gzip")
+
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+func setBody(r *http.Request, body []byte) {
+	r.ContentLength = int64(len(body))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
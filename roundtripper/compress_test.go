@@ -0,0 +1,93 @@
+package roundtripper_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gromey/proto-rest/roundtripper"
+)
+
+func TestCompressRequest(t *testing.T) {
+	var tests = []struct {
+		name          string
+		body          string
+		unknownLength bool
+		encoding      string
+		expEncoding   string
+	}{
+		{
+			name:        "compressed body",
+			body:        strings.Repeat("a", 100),
+			expEncoding: "gzip",
+		},
+		{
+			name:          "compressed body of unknown length",
+			body:          strings.Repeat("a", 100),
+			unknownLength: true,
+			expEncoding:   "gzip",
+		},
+		{
+			name: "small body",
+			body: strings.Repeat("a", 99),
+		},
+		{
+			name:          "small body of unknown length",
+			body:          strings.Repeat("a", 99),
+			unknownLength: true,
+		},
+		{
+			name:        "already encoded body",
+			body:        strings.Repeat("a", 100),
+			encoding:    "br",
+			expEncoding: "br",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				raw, err := io.ReadAll(r.Body)
+				equal(t, nil, err)
+
+				equal(t, test.expEncoding, r.Header.Get("Content-Encoding"))
+				equal(t, strconv.Itoa(len(raw)), r.Header.Get("Content-Length"))
+				equal(t, int64(len(raw)), r.ContentLength)
+
+				body := string(raw)
+				if test.expEncoding == "gzip" {
+					zr, err := gzip.NewReader(strings.NewReader(body))
+					equal(t, nil, err)
+					p, err := io.ReadAll(zr)
+					equal(t, nil, err)
+					equal(t, true, len(raw) < len(p))
+					body = string(p)
+				}
+				equal(t, test.body, body)
+			}))
+			defer srv.Close()
+
+			clt := &http.Client{Transport: roundtripper.Sequencer(http.DefaultTransport, roundtripper.CompressRequest(100, gzip.BestCompression))}
+
+			var body io.Reader = strings.NewReader(test.body)
+			if test.unknownLength {
+				body = io.NopCloser(body)
+			}
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL, body)
+			equal(t, nil, err)
+			if test.encoding != "" {
+				req.Header.Set("Content-Encoding", test.encoding)
+			}
+
+			resp, err := clt.Do(req)
+			equal(t, nil, err)
+			defer func() { _ = resp.Body.Close() }()
+			equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package roundtripper
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gromey/proto-rest/utils"
+)
+
+// DeadlinePropagation sends the time remaining until the deadline of the request context
+// in the given header, in grpc-timeout format, so the server can stop working when the caller gives up.
+// Use it with middleware.Timeout on the server side; an empty header defaults to "X-Request-Timeout".
+// Requests whose deadline has already passed fail with context.DeadlineExceeded without being sent.
+func DeadlinePropagation(header string) func(http.RoundTripper) http.RoundTripper {
+	if header == "" {
+		header = "X-Request-Timeout"
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return Func(func(r *http.Request) (*http.Response, error) {
+			deadline, ok := r.Context().Deadline()
+			if !ok {
+				return next.RoundTrip(r)
+			}
+
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return nil, context.DeadlineExceeded
+			}
+
+			r = r.Clone(r.Context())
+			r.Header.Set(header, utils.FormatTimeout(remaining))
+
+			return next.RoundTrip(r)
+		})
+	}
+}
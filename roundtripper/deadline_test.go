@@ -0,0 +1,103 @@
+package roundtripper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/roundtripper"
+	"github.com/gromey/proto-rest/utils"
+)
+
+func TestDeadlinePropagation(t *testing.T) {
+	var tests = []struct {
+		name    string
+		header  string
+		timeout time.Duration
+		expUnit string
+	}{
+		{
+			name: "no deadline",
+		},
+		{
+			name:    "nanoseconds",
+			timeout: 50 * time.Millisecond,
+			expUnit: "n",
+		},
+		{
+			name:    "microseconds",
+			timeout: time.Second,
+			expUnit: "u",
+		},
+		{
+			name:    "milliseconds",
+			timeout: 5 * time.Minute,
+			expUnit: "m",
+		},
+		{
+			name:    "seconds",
+			timeout: 1000 * time.Hour,
+			expUnit: "S",
+		},
+		{
+			name:    "custom header",
+			header:  "Grpc-Timeout",
+			timeout: time.Second,
+			expUnit: "u",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := test.header
+			if header == "" {
+				header = "X-Request-Timeout"
+			}
+
+			var got string
+			rt := roundtripper.DeadlinePropagation(test.header)(roundtripper.Func(func(r *http.Request) (*http.Response, error) {
+				got = r.Header.Get(header)
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			}))
+
+			ctx := context.Background()
+			if test.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.timeout)
+				defer cancel()
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/path", nil)
+			equal(t, nil, err)
+
+			_, err = rt.RoundTrip(req)
+			equal(t, nil, err)
+
+			if test.timeout == 0 {
+				equal(t, "", got)
+				return
+			}
+			equal(t, true, strings.HasSuffix(got, test.expUnit))
+			d, err := utils.ParseTimeout(got)
+			equal(t, nil, err)
+			equal(t, true, d > 0 && d <= test.timeout)
+		})
+	}
+
+	t.Run("expired deadline", func(t *testing.T) {
+		rt := roundtripper.DeadlinePropagation("")(roundtripper.Func(func(r *http.Request) (*http.Response, error) {
+			t.Fatal("the request must not be sent")
+			return nil, nil
+		}))
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/path", nil)
+		equal(t, nil, err)
+
+		_, err = rt.RoundTrip(req)
+		equal(t, true, errors.Is(err, context.DeadlineExceeded))
+	})
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConditionalServer is a Server that supports conditional requests.
+// The Servers returned by New and NewWithOptions implement it:
+//
+//	srv := server.NewWithOptions(coder, opts).(server.ConditionalServer)
+type ConditionalServer interface {
+	Server
+	WriteConditionalResponse(w http.ResponseWriter, r *http.Request, statusCode int, v any)
+	CheckPreconditions(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool
+}
+
+// ETagMode defines how WriteConditionalResponse computes entity tags.
+type ETagMode uint8
+
+const (
+	ETagStrong ETagMode = iota // Strong entity tags, the default.
+	ETagWeak                   // Weak entity tags, prefixed with W/.
+	ETagNone                   // Entity tags are not computed, only those set by the handler are used.
+)
+
+// ETag returns an entity tag computed from the SHA-256 digest of b.
+func ETag(b []byte, weak bool) string {
+	sum := sha256.Sum256(b)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// evaluatePreconditions evaluates the conditional headers of r against the current state of the resource
+// in the order defined by RFC 9110, section 13.2.2. It returns 0 if the request should be processed,
+// or the status code to respond with otherwise.
+func evaluatePreconditions(r *http.Request, etag string, lastModified time.Time) int {
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !matchETag(im, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(ius) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if matchETag(inm, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(ims) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// matchETag reports whether etag matches any entity tag in the header value list.
+// Weak comparison ignores the W/ prefix, strong comparison never matches weak tags.
+func matchETag(list, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPreconditions evaluates If-Match, If-Unmodified-Since, If-None-Match and If-Modified-Since
+// against the current entity tag and modification time of the resource.
+// Use it in PUT, PATCH and DELETE handlers for optimistic concurrency control before changing the resource.
+// If the request must not be processed, the status (304, 412 or 428) is written and false is returned.
+func (s *protoServer) CheckPreconditions(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	if s.opts.RequirePreconditions && r.Method != http.MethodGet && r.Method != http.MethodHead &&
+		r.Header.Get("If-Match") == "" && r.Header.Get("If-Unmodified-Since") == "" {
+		s.WriteResponse(w, http.StatusPreconditionRequired, nil)
+		return false
+	}
+
+	if status := evaluatePreconditions(r, etag, lastModified); status != 0 {
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		s.WriteResponse(w, status, nil)
+		return false
+	}
+
+	return true
+}
+
+// WriteConditionalResponse encodes the value pointed to by v and writes it and statusCode to the stream,
+// answering GET and HEAD requests with 304 Not Modified when the client already has the representation,
+// or with 412 Precondition Failed when If-Match or If-Unmodified-Since doesn't match it.
+// The ETag header set by the handler is used if present, otherwise one is computed from the encoded bytes.
+// The Last-Modified header set by the handler is used for If-Modified-Since.
+func (s *protoServer) WriteConditionalResponse(w http.ResponseWriter, r *http.Request, statusCode int, v any) {
+	if v == nil {
+		s.WriteResponse(w, statusCode, nil)
+		return
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if !s.encodeResponse(w, buf, v) {
+		return
+	}
+
+	etag := w.Header().Get("ETag")
+	if etag == "" && s.opts.ETag != ETagNone && statusCode >= 200 && statusCode < 300 {
+		etag = ETag(buf.Bytes(), s.opts.ETag == ETagWeak)
+		w.Header().Set("ETag", etag)
+	}
+
+	if statusCode >= 200 && statusCode < 300 && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		lastModified, _ := http.ParseTime(w.Header().Get("Last-Modified"))
+		if status := evaluatePreconditions(r, etag, lastModified); status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+	}
+
+	s.writeBuffer(w, statusCode, v, buf, r.Method != http.MethodHead)
+}
@@ -0,0 +1,161 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/server"
+)
+
+func TestProtoServer_WriteConditionalResponse(t *testing.T) {
+	output := &exampleStructClt{Field: "example"}
+	etag := server.ETag([]byte(`{"Field":"example"}`), false)
+
+	var tests = []struct {
+		name      string
+		opts      *server.Options
+		method    string
+		header    map[string]string
+		expStatus int
+		expETag   string
+	}{
+		{
+			name:      "no conditional headers",
+			method:    http.MethodGet,
+			expStatus: http.StatusOK,
+			expETag:   etag,
+		},
+		{
+			name:      "matching If-None-Match",
+			method:    http.MethodGet,
+			header:    map[string]string{"If-None-Match": etag},
+			expStatus: http.StatusNotModified,
+			expETag:   etag,
+		},
+		{
+			name:      "weak If-None-Match",
+			opts:      &server.Options{ETag: server.ETagWeak},
+			method:    http.MethodGet,
+			header:    map[string]string{"If-None-Match": etag},
+			expStatus: http.StatusNotModified,
+			expETag:   "W/" + etag,
+		},
+		{
+			name:      "not matching If-None-Match",
+			method:    http.MethodGet,
+			header:    map[string]string{"If-None-Match": `"other"`},
+			expStatus: http.StatusOK,
+			expETag:   etag,
+		},
+		{
+			name:      "matching If-Match",
+			method:    http.MethodGet,
+			header:    map[string]string{"If-Match": etag},
+			expStatus: http.StatusOK,
+			expETag:   etag,
+		},
+		{
+			name:      "not matching If-Match",
+			method:    http.MethodGet,
+			header:    map[string]string{"If-Match": `"other"`},
+			expStatus: http.StatusPreconditionFailed,
+			expETag:   etag,
+		},
+		{
+			name:      "not matching If-Match on HEAD",
+			method:    http.MethodHead,
+			header:    map[string]string{"If-Match": `"other"`},
+			expStatus: http.StatusPreconditionFailed,
+			expETag:   etag,
+		},
+		{
+			name:      "no entity tag",
+			opts:      &server.Options{ETag: server.ETagNone},
+			method:    http.MethodGet,
+			header:    map[string]string{"If-None-Match": etag},
+			expStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := server.NewWithOptions(cdrJSON, test.opts).(server.ConditionalServer)
+
+			r := httptest.NewRequest(test.method, "/path", nil)
+			for k, v := range test.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			srv.WriteConditionalResponse(w, r, http.StatusOK, output)
+
+			equal(t, test.expStatus, w.Code)
+			equal(t, test.expETag, w.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestProtoServer_CheckPreconditions(t *testing.T) {
+	etag := `"v1"`
+	lastModified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name      string
+		opts      *server.Options
+		method    string
+		header    map[string]string
+		expOk     bool
+		expStatus int
+	}{
+		{
+			name:      "matching If-Match",
+			method:    http.MethodPut,
+			header:    map[string]string{"If-Match": etag},
+			expOk:     true,
+			expStatus: http.StatusOK,
+		},
+		{
+			name:      "not matching If-Match",
+			method:    http.MethodPut,
+			header:    map[string]string{"If-Match": `"v0"`},
+			expStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:      "modified since If-Unmodified-Since",
+			method:    http.MethodPatch,
+			header:    map[string]string{"If-Unmodified-Since": lastModified.Add(-time.Hour).Format(http.TimeFormat)},
+			expStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:      "not modified since If-Modified-Since",
+			method:    http.MethodGet,
+			header:    map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)},
+			expStatus: http.StatusNotModified,
+		},
+		{
+			name:      "precondition required",
+			opts:      &server.Options{RequirePreconditions: true},
+			method:    http.MethodPut,
+			expStatus: http.StatusPreconditionRequired,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := server.NewWithOptions(cdrJSON, test.opts).(server.ConditionalServer)
+
+			r := httptest.NewRequest(test.method, "/path", nil)
+			for k, v := range test.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			ok := srv.CheckPreconditions(w, r, etag, lastModified)
+
+			equal(t, test.expOk, ok)
+			equal(t, test.expStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+)
+
+// Link represents a web link (RFC 8288).
+type Link struct {
+	URL string // The target URL.
+	Rel string // The relation type.
+}
+
+// String returns the link in the format of the Link header, e.g. <https://example.com/?cursor=x>; rel="next".
+func (l Link) String() string {
+	return "<" + l.URL + `>; rel="` + l.Rel + `"`
+}
+
+// Linker is implemented by response values with links, which WriteResponse writes to the Link header.
+type Linker interface {
+	Links() []Link
+}
+
+// setLinks adds the links of a Linker value to the Link header.
+func setLinks(w http.ResponseWriter, v any) {
+	if l, ok := v.(Linker); ok {
+		for _, link := range l.Links() {
+			w.Header().Add("Link", link.String())
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gromey/proto-rest/coder"
+	"github.com/gromey/proto-rest/errors"
+)
+
+// Negotiate returns a Server for the request whose Coder decodes the body according to its Content-Type
+// and encodes responses according to the Accept header, so handlers serve every format of the Negotiator.
+// If no Coder matches, it returns errors.ErrUnsupportedMediaType or errors.ErrNotAcceptable together with
+// a Server that writes with the default Coder, to respond with the error.
+func Negotiate(w http.ResponseWriter, r *http.Request, n *coder.Negotiator, opts *Options) (Server, error) {
+	w.Header().Add("Vary", "Accept")
+
+	enc, ok := n.ForAccept(r.Header.Get("Accept"))
+	if !ok {
+		return NewWithOptions(n.Default(), opts), errors.ErrNotAcceptable
+	}
+
+	dec := enc
+	if r.ContentLength != 0 || r.Header.Get(coder.ContentType) != "" {
+		if dec, ok = n.ForContentType(r.Header.Get(coder.ContentType)); !ok {
+			return NewWithOptions(enc, opts), errors.ErrUnsupportedMediaType
+		}
+	}
+
+	return NewWithOptions(&negotiatedCoder{Coder: enc, dec: dec, contentType: r.Header.Get(coder.ContentType)}, opts), nil
+}
+
+// negotiatedCoder encodes with the embedded Coder and decodes with dec,
+// passing it the Content-Type of the request.
+type negotiatedCoder struct {
+	coder.Coder
+	dec         coder.Coder
+	contentType string
+}
+
+func (c *negotiatedCoder) Decode(r io.Reader, v any) error {
+	return coder.DecodeMediaType(c.dec, r, c.contentType, v)
+}
@@ -0,0 +1,94 @@
+package server_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gromey/proto-rest/coder"
+	"github.com/gromey/proto-rest/errors"
+	"github.com/gromey/proto-rest/server"
+)
+
+func TestNegotiate(t *testing.T) {
+	msgpackCoder := coder.NewMsgpackCoder()
+	n := coder.NewNegotiator(cdrJSON, msgpackCoder)
+
+	msgpackBody := new(bytes.Buffer)
+	equal(t, nil, msgpackCoder.Encode(msgpackBody, &exampleStructClt{Field: "in"}))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv, err := server.Negotiate(w, r, n, nil)
+		if err != nil {
+			srv.WriteResponse(w, err.(errors.Error).Code(), errors.AsProblem(err))
+			return
+		}
+
+		in := new(exampleStructClt)
+		if r.ContentLength > 0 {
+			equal(t, nil, srv.Decode(r.Body, in))
+		}
+		srv.WriteResponse(w, http.StatusOK, in)
+	})
+
+	var tests = []struct {
+		name        string
+		contentType string
+		accept      string
+		body        []byte
+		expStatus   int
+		expType     string
+		expBody     string
+	}{
+		{
+			name:        "msgpack request, json response",
+			contentType: "application/msgpack",
+			accept:      "application/json",
+			body:        msgpackBody.Bytes(),
+			expStatus:   http.StatusOK,
+			expType:     "application/json",
+			expBody:     `{"Field":"in"}`,
+		},
+		{
+			name:      "msgpack response",
+			accept:    "application/msgpack",
+			expStatus: http.StatusOK,
+			expType:   "application/msgpack",
+			expBody:   "\x81\xa5Field\xa0",
+		},
+		{
+			name:      "not acceptable",
+			accept:    "text/html",
+			expStatus: http.StatusNotAcceptable,
+			expType:   "application/json",
+			expBody:   `{"title":"Not Acceptable","status":406,"detail":"not acceptable"}`,
+		},
+		{
+			name:        "unsupported media type",
+			contentType: "text/plain",
+			body:        []byte("x"),
+			expStatus:   http.StatusUnsupportedMediaType,
+			expType:     "application/json",
+			expBody:     `{"title":"Unsupported Media Type","status":415,"detail":"unsupported media type"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			req.Header.Set("Accept", test.accept)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			equal(t, test.expStatus, rec.Code)
+			equal(t, test.expType, rec.Header().Get("Content-Type"))
+			equal(t, "Accept", rec.Header().Get("Vary"))
+			equal(t, test.expBody, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gromey/proto-rest/logger"
+)
+
+// ListenerConfig represents a listener the Runner serves on.
+type ListenerConfig struct {
+	Network   string      // "tcp" (the default), "tcp4", "tcp6" or "unix".
+	Addr      string      // The address, or the socket path for "unix".
+	TLSConfig *tls.Config // Serves HTTPS with this configuration.
+	CertFile  string      // Serves HTTPS with the certificate in this file, together with KeyFile.
+	KeyFile   string      // The private key for CertFile.
+}
+
+// RunnerOptions represents options for configuring the Runner.
+type RunnerOptions struct {
+	Handler         http.Handler     // The handler to serve. Required.
+	Listeners       []ListenerConfig // The listeners to serve on. Required.
+	HTTPServer      *http.Server     // A template for timeouts and limits. Its Handler and Addr are ignored.
+	ShutdownTimeout time.Duration    // How long in-flight requests are drained. Defaults to 30 seconds.
+	ReadinessDelay  time.Duration    // How long the Runner reports not ready before it stops accepting requests.
+	Signals         []os.Signal      // The signals that start the shutdown. Defaults to SIGINT and SIGTERM.
+}
+
+// Runner runs an HTTP server on one or more listeners and shuts it down gracefully.
+type Runner struct {
+	opts *RunnerOptions
+	srv  *http.Server
+
+	ready int32
+
+	mu    sync.Mutex
+	hooks []shutdownHook
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type shutdownHook struct {
+	name string
+	f    func(ctx context.Context) error
+}
+
+// NewRunner returns a new Runner.
+func NewRunner(opts *RunnerOptions) *Runner {
+	srv := new(http.Server)
+	if opts.HTTPServer != nil {
+		srv.ReadTimeout = opts.HTTPServer.ReadTimeout
+		srv.ReadHeaderTimeout = opts.HTTPServer.ReadHeaderTimeout
+		srv.WriteTimeout = opts.HTTPServer.WriteTimeout
+		srv.IdleTimeout = opts.HTTPServer.IdleTimeout
+		srv.MaxHeaderBytes = opts.HTTPServer.MaxHeaderBytes
+		srv.ErrorLog = opts.HTTPServer.ErrorLog
+		srv.BaseContext = opts.HTTPServer.BaseContext
+		srv.ConnContext = opts.HTTPServer.ConnContext
+	}
+	srv.Handler = opts.Handler
+
+	return &Runner{opts: opts, srv: srv, stop: make(chan struct{})}
+}
+
+// OnShutdown registers a hook that runs after the server has stopped, e.g. to close database connections.
+// Hooks run in the order they were registered and share the remaining shutdown deadline.
+func (r *Runner) OnShutdown(name string, f func(ctx context.Context) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, shutdownHook{name: name, f: f})
+}
+
+// Ready reports whether the Runner accepts requests. It turns false as soon as the shutdown starts.
+func (r *Runner) Ready() bool {
+	return atomic.LoadInt32(&r.ready) == 1
+}
+
+// Shutdown starts the graceful shutdown, as if a signal was received.
+func (r *Runner) Shutdown() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+// Run starts the listeners and blocks until a signal is received, ctx is done, Shutdown is called
+// or a listener fails. It then reports not ready, waits ReadinessDelay, drains in-flight requests
+// and runs the shutdown hooks. It returns the first error that occurred.
+func (r *Runner) Run(ctx context.Context) error {
+	listeners := make([]net.Listener, 0, len(r.opts.Listeners))
+	for _, cfg := range r.opts.Listeners {
+		l, err := listen(cfg)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, l)
+	}
+
+	errChan := make(chan error, len(listeners))
+	for _, l := range listeners {
+		if logger.InLevel(logger.LevelInfo) {
+			logger.Infof("Server is listening on %s %s", l.Addr().Network(), l.Addr())
+		}
+		go func(l net.Listener) {
+			if err := r.srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errChan <- err
+			}
+		}(l)
+	}
+	atomic.StoreInt32(&r.ready, 1)
+
+	signals := r.opts.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, signals...)
+	defer signal.Stop(sigChan)
+
+	var runErr error
+	select {
+	case sig := <-sigChan:
+		if logger.InLevel(logger.LevelInfo) {
+			logger.Infof("Received signal %s, shutting down", sig)
+		}
+	case <-ctx.Done():
+		if logger.InLevel(logger.LevelInfo) {
+			logger.Info("Context is done, shutting down")
+		}
+	case <-r.stop:
+		if logger.InLevel(logger.LevelInfo) {
+			logger.Info("Shutdown requested")
+		}
+	case runErr = <-errChan:
+		if logger.InLevel(logger.LevelError) {
+			logger.Error("Server failed, shutting down. Error: ", runErr)
+		}
+	}
+
+	if err := r.shutdown(); err != nil && runErr == nil {
+		runErr = err
+	}
+
+	return runErr
+}
+
+func (r *Runner) shutdown() error {
+	atomic.StoreInt32(&r.ready, 0)
+
+	if r.opts.ReadinessDelay > 0 {
+		if logger.InLevel(logger.LevelInfo) {
+			logger.Infof("Reporting not ready for %s", r.opts.ReadinessDelay)
+		}
+		time.Sleep(r.opts.ReadinessDelay)
+	}
+
+	timeout := r.opts.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var firstErr error
+
+	if err := r.srv.Shutdown(ctx); err != nil {
+		if logger.InLevel(logger.LevelError) {
+			logger.Error("Can't drain in-flight requests. Error: ", err)
+		}
+		_ = r.srv.Close()
+		firstErr = err
+	} else if logger.InLevel(logger.LevelInfo) {
+		logger.Info("Server stopped")
+	}
+
+	r.mu.Lock()
+	hooks := r.hooks
+	r.mu.Unlock()
+
+	for _, hook := range hooks {
+		if err := hook.f(ctx); err != nil {
+			if logger.InLevel(logger.LevelError) {
+				logger.Errorf("Shutdown hook %s failed. Error: %v", hook.name, err)
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("shutdown hook %s: %w", hook.name, err)
+			}
+			continue
+		}
+		if logger.InLevel(logger.LevelInfo) {
+			logger.Infof("Shutdown hook %s done", hook.name)
+		}
+	}
+
+	return firstErr
+}
+
+func listen(cfg ListenerConfig) (net.Listener, error) {
+	network := cfg.Network
+	if network == "" {
+		network = "tcp"
+	}
+
+	if network == "unix" {
+		// Remove a socket left behind by a previous run, but not one a running server still listens on.
+		if fi, err := os.Stat(cfg.Addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			conn, err := net.DialTimeout(network, cfg.Addr, time.Second)
+			if err == nil {
+				_ = conn.Close()
+			} else if errors.Is(err, syscall.ECONNREFUSED) {
+				_ = os.Remove(cfg.Addr)
+			}
+		}
+	}
+
+	l, err := net.Listen(network, cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := cfg.TLSConfig
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			_ = l.Close()
+			return nil, err
+		}
+		if tlsConfig == nil {
+			tlsConfig = new(tls.Config)
+		} else {
+			tlsConfig = tlsConfig.Clone()
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	if tlsConfig != nil {
+		if len(tlsConfig.NextProtos) == 0 {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+		}
+		l = tls.NewListener(l, tlsConfig)
+	}
+
+	return l, nil
+}
@@ -0,0 +1,184 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/server"
+)
+
+func TestRunner_Run(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "server.sock")
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+
+	runner := server.NewRunner(&server.RunnerOptions{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-finish
+			w.WriteHeader(http.StatusAccepted)
+		}),
+		Listeners:       []server.ListenerConfig{{Network: "unix", Addr: socket}},
+		ShutdownTimeout: time.Second,
+	})
+
+	var hooks []string
+	runner.OnShutdown("first", func(ctx context.Context) error {
+		hooks = append(hooks, "first")
+		return nil
+	})
+	runner.OnShutdown("second", func(ctx context.Context) error {
+		hooks = append(hooks, "second")
+		return nil
+	})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- runner.Run(context.Background()) }()
+
+	clt := unixClient(socket)
+
+	var resp *http.Response
+	respErr := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < 100; i++ {
+			if resp, err = clt.Get("http://unix/path"); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		respErr <- err
+	}()
+
+	<-started
+	equal(t, true, runner.Ready())
+
+	runner.Shutdown()
+	waitFor(t, func() bool { return !runner.Ready() })
+
+	// The in-flight request is drained before Run returns.
+	close(finish)
+	equal(t, nil, <-respErr)
+	equal(t, http.StatusAccepted, resp.StatusCode)
+	equal(t, nil, <-runErr)
+	equal(t, []string{"first", "second"}, hooks)
+}
+
+// waitFor polls cond until it is true, or fails the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// unixClient returns a client that sends every request to the unix socket.
+func unixClient(socket string) *http.Client {
+	return &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return new(net.Dialer).DialContext(ctx, "unix", socket)
+	}}}
+}
+
+func TestRunner_RunReadinessDelay(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "server.sock")
+	runner := server.NewRunner(&server.RunnerOptions{
+		Handler:        http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		Listeners:      []server.ListenerConfig{{Network: "unix", Addr: socket}},
+		ReadinessDelay: 200 * time.Millisecond,
+	})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- runner.Run(context.Background()) }()
+	waitFor(t, runner.Ready)
+
+	start := time.Now()
+	runner.Shutdown()
+	waitFor(t, func() bool { return !runner.Ready() })
+
+	// Requests are still served while the Runner reports not ready.
+	resp, err := unixClient(socket).Get("http://unix/path")
+	equal(t, nil, err)
+	_ = resp.Body.Close()
+	equal(t, http.StatusOK, resp.StatusCode)
+
+	equal(t, nil, <-runErr)
+	equal(t, true, time.Since(start) >= 200*time.Millisecond)
+}
+
+func TestRunner_RunHookError(t *testing.T) {
+	runner := server.NewRunner(&server.RunnerOptions{
+		Handler:   http.NotFoundHandler(),
+		Listeners: []server.ListenerConfig{{Network: "unix", Addr: filepath.Join(t.TempDir(), "server.sock")}},
+	})
+
+	hookErr := errors.New("hook failed")
+	var ran []string
+	runner.OnShutdown("first", func(ctx context.Context) error {
+		ran = append(ran, "first")
+		return hookErr
+	})
+	runner.OnShutdown("second", func(ctx context.Context) error {
+		ran = append(ran, "second")
+		return errors.New("second hook failed")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := runner.Run(ctx)
+	equal(t, true, errors.Is(err, hookErr))
+	equal(t, "shutdown hook first: hook failed", err.Error())
+	// A failing hook doesn't stop the next ones.
+	equal(t, []string{"first", "second"}, ran)
+}
+
+func TestRunner_RunStaleSocket(t *testing.T) {
+	var tests = []struct {
+		name   string
+		stale  bool
+		expErr bool
+	}{
+		{
+			name:  "socket left behind is removed",
+			stale: true,
+		},
+		{
+			name:   "socket in use is kept",
+			expErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			socket := filepath.Join(t.TempDir(), "server.sock")
+
+			l, err := net.Listen("unix", socket)
+			equal(t, nil, err)
+			l.(*net.UnixListener).SetUnlinkOnClose(false)
+			defer func() { _ = l.Close() }()
+			if test.stale {
+				_ = l.Close()
+			}
+
+			runner := server.NewRunner(&server.RunnerOptions{
+				Handler:   http.NotFoundHandler(),
+				Listeners: []server.ListenerConfig{{Network: "unix", Addr: socket}},
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err = runner.Run(ctx)
+			equal(t, test.expErr, err != nil)
+		})
+	}
+}
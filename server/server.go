@@ -1,9 +1,14 @@
 package server
 
 import (
+	"bytes"
+	"io"
 	"net/http"
+	"strconv"
+	"sync"
 
 	"github.com/gromey/proto-rest/coder"
+	"github.com/gromey/proto-rest/errors"
 	"github.com/gromey/proto-rest/logger"
 )
 
@@ -12,31 +17,130 @@ type Server interface {
 	WriteResponse(w http.ResponseWriter, statusCode int, v any)
 }
 
+// Options represents options for configuring the Server.
+type Options struct {
+	ETag                 ETagMode // How WriteConditionalResponse computes entity tags.
+	RequirePreconditions bool     // CheckPreconditions responds 428 to unsafe requests without If-Match or If-Unmodified-Since.
+}
+
 type protoServer struct {
 	coder.Coder
+	opts *Options
 }
 
 // New returns a new Server.
 func New(coder coder.Coder) Server {
-	return &protoServer{Coder: coder}
+	return NewWithOptions(coder, nil)
+}
+
+// NewWithOptions returns a new Server configured with opts.
+func NewWithOptions(coder coder.Coder, opts *Options) Server {
+	if opts == nil {
+		opts = new(Options)
+	}
+	return &protoServer{Coder: coder, opts: opts}
+}
+
+// DecodeMediaType decodes with the content type if the Coder is a coder.MediaTypeDecoder, otherwise it calls Decode.
+func (s *protoServer) DecodeMediaType(r io.Reader, contentType string, v any) error {
+	return coder.DecodeMediaType(s.Coder, r, contentType, v)
+}
+
+// DecodeRequest decodes the body of r with srv into the value pointed to by v. The Content-Type of r is passed
+// to coders that need its parameters, such as the boundary of multipart/form-data.
+func DecodeRequest(srv Server, r *http.Request, v any) error {
+	return coder.DecodeMediaType(srv, r.Body, r.Header.Get(coder.ContentType), v)
 }
 
 // WriteResponse encodes the value pointed to by v and writes it and statusCode to the stream.
+// The links of a Linker value, such as a pagination.Page, are written to the Link header.
+// The value is encoded before anything is written, so if encoding fails a 500 problem document is written instead.
 func (s *protoServer) WriteResponse(w http.ResponseWriter, statusCode int, v any) {
-	if v != nil {
-		if w.Header().Get(coder.ContentType) == "" {
-			if t := s.ContentType(); t != "" {
-				w.Header().Set(coder.ContentType, t)
-			}
-		}
+	if v == nil {
+		w.WriteHeader(statusCode)
+		return
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if s.encodeResponse(w, buf, v) {
+		s.writeBuffer(w, statusCode, v, buf, true)
+	}
+}
+
+// encodeResponse encodes v into buf. If encoding fails, it writes a 500 problem document
+// encoded with the Coder, or a plain text error if that fails too, and returns false.
+func (s *protoServer) encodeResponse(w http.ResponseWriter, buf *bytes.Buffer, v any) bool {
+	err := s.Encode(buf, v)
+	if err == nil {
+		return true
+	}
+
+	if logger.InLevel(logger.LevelError) {
+		logger.Error("Can't encode response. Error: ", err)
+	}
+
+	// The headers set by the handler describe the representation that couldn't be encoded.
+	for _, key := range []string{coder.ContentType, "Content-Length", "ETag", "Last-Modified", "Link"} {
+		w.Header().Del(key)
+	}
+
+	buf.Reset()
+	if err = s.Encode(buf, errors.NewProblem(http.StatusInternalServerError, "")); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return false
+	}
+
+	s.writeBuffer(w, http.StatusInternalServerError, nil, buf, true)
+	return false
+}
+
+// writeBuffer sets the Content-Type, Link and Content-Length headers and writes statusCode and,
+// if body is true and the status allows one, the encoded value in buf.
+func (s *protoServer) writeBuffer(w http.ResponseWriter, statusCode int, v any, buf *bytes.Buffer, body bool) {
+	if !bodyAllowed(statusCode) {
 		w.WriteHeader(statusCode)
-		if err := s.Encode(w, v); err != nil {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			if logger.InLevel(logger.LevelError) {
-				logger.Error("Can't encode response. Error: ", err)
-			}
-		}
 		return
 	}
+
+	s.setContentType(w)
+	setLinks(w, v)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
 	w.WriteHeader(statusCode)
+	if body {
+		_, _ = buf.WriteTo(w)
+	}
+}
+
+// bodyAllowed reports whether a response with statusCode may have a body (RFC 9110, section 6.4.1).
+func bodyAllowed(statusCode int) bool {
+	return statusCode >= 200 && statusCode != http.StatusNoContent && statusCode != http.StatusNotModified
+}
+
+// maxPooledBuffer is the capacity above which buffers are not returned to the pool,
+// so a single large response doesn't keep its memory alive.
+const maxPooledBuffer = 64 << 10
+
+var bufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// setContentType sets the Content-Type header to the Coder content type unless the handler has already set it.
+func (s *protoServer) setContentType(w http.ResponseWriter) {
+	if w.Header().Get(coder.ContentType) == "" {
+		if t := s.ContentType(); t != "" {
+			w.Header().Set(coder.ContentType, t)
+		}
+	}
 }
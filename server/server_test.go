@@ -3,13 +3,16 @@ package server_test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"testing"
 
 	"github.com/gromey/proto-rest/coder"
+	protoerrors "github.com/gromey/proto-rest/errors"
 	"github.com/gromey/proto-rest/logger"
 	"github.com/gromey/proto-rest/server"
 )
@@ -170,3 +173,108 @@ func TestProtoServer_ResponseContentType(t *testing.T) {
 		})
 	}
 }
+
+func TestProtoServer_WriteResponseEncodeError(t *testing.T) {
+	errEncode := errors.New("encode error")
+
+	// cdrFailing fails to encode anything but a problem document.
+	cdrFailing := coder.NewCoder("application/json", func(v any) ([]byte, error) {
+		if _, ok := v.(*protoerrors.Problem); ok {
+			return json.Marshal(v)
+		}
+		return nil, errEncode
+	}, json.Unmarshal)
+
+	cdrBroken := coder.NewCoder("application/json", func(v any) ([]byte, error) {
+		return nil, errEncode
+	}, json.Unmarshal)
+
+	var tests = []struct {
+		name           string
+		coder          coder.Coder
+		expCode        int
+		expContentType string
+		expETag        string
+		expBody        string
+		expLength      bool
+	}{
+		{
+			name:           "successful encoding",
+			coder:          cdrJSON,
+			expCode:        http.StatusCreated,
+			expContentType: "application/custom",
+			expETag:        `"etag"`,
+			expBody:        `{"Field":"example"}`,
+			expLength:      true,
+		},
+		{
+			name:           "problem document",
+			coder:          cdrFailing,
+			expCode:        http.StatusInternalServerError,
+			expContentType: "application/json",
+			expBody:        `{"title":"Internal Server Error","status":500}`,
+			expLength:      true,
+		},
+		{
+			name:           "plain text error",
+			coder:          cdrBroken,
+			expCode:        http.StatusInternalServerError,
+			expContentType: "text/plain; charset=utf-8",
+			expBody:        "Internal Server Error\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := server.New(test.coder)
+
+			w := httptest.NewRecorder()
+			w.Header().Set(coder.ContentType, "application/custom")
+			w.Header().Set("ETag", `"etag"`)
+			srv.WriteResponse(w, http.StatusCreated, &exampleStructClt{Field: "example"})
+
+			equal(t, test.expCode, w.Code)
+			equal(t, test.expContentType, w.Header().Get(coder.ContentType))
+			equal(t, test.expETag, w.Header().Get("ETag"))
+			equal(t, test.expBody, w.Body.String())
+			if test.expLength {
+				equal(t, strconv.Itoa(len(test.expBody)), w.Header().Get("Content-Length"))
+			}
+		})
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	type form struct {
+		Text string `form:"text"`
+	}
+
+	mc := coder.NewMultipartCoder(nil)
+	srv := server.New(mc)
+
+	buf := new(bytes.Buffer)
+	contentType, err := mc.(coder.MediaTypeEncoder).EncodeMediaType(buf, &form{Text: "a"})
+	equal(t, nil, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/path", buf)
+	r.Header.Set(coder.ContentType, contentType)
+
+	out := new(form)
+	equal(t, nil, server.DecodeRequest(srv, r, out))
+	equal(t, "a", out.Text)
+}
+
+type linkedStruct struct {
+	Field string
+}
+
+func (linkedStruct) Links() []server.Link {
+	return []server.Link{{URL: "/items?cursor=a", Rel: "next"}}
+}
+
+func TestProtoServer_WriteResponseLinks(t *testing.T) {
+	w := httptest.NewRecorder()
+	server.New(cdrJSON).WriteResponse(w, http.StatusOK, linkedStruct{Field: "a"})
+
+	equal(t, []string{`</items?cursor=a>; rel="next"`}, w.Header().Values("Link"))
+}
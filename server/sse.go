@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gromey/proto-rest/coder"
+)
+
+// Event represents a Server-Sent Event.
+type Event struct {
+	ID    string        // Sets the last event ID the browser sends back when it reconnects.
+	Event string        // The event type. Empty means "message".
+	Retry time.Duration // Tells the browser how long to wait before reconnecting. Zero omits the field.
+	Data  any           // The value encoded with the Coder. Nil sends an event without data.
+}
+
+// ErrStreamingUnsupported is returned when the http.ResponseWriter can't be flushed.
+var ErrStreamingUnsupported = errors.New("server: streaming unsupported by the response writer")
+
+// ErrMultilineField is returned when the ID or the Event of an event contains a line break,
+// which would let it inject other fields into the stream.
+var ErrMultilineField = errors.New("server: event field contains a line break")
+
+// EventWriter writes Server-Sent Events (text/event-stream) to a response.
+// It is safe for concurrent use.
+type EventWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	coder   coder.Coder
+	r       *http.Request
+
+	mu     sync.Mutex
+	done   chan struct{}
+	closed bool
+}
+
+// NewEventWriter starts a Server-Sent Events response. The event data is encoded with c, e.g. a Server.
+// If heartbeat is positive, a comment is sent at that interval to keep idle connections open.
+// The stream stops when the request context is done or Close is called.
+func NewEventWriter(w http.ResponseWriter, r *http.Request, c coder.Coder, heartbeat time.Duration) (*EventWriter, error) {
+	flusher := findFlusher(w)
+	if flusher == nil {
+		return nil, ErrStreamingUnsupported
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ew := &EventWriter{w: w, flusher: flusher, coder: c, r: r, done: make(chan struct{})}
+
+	go ew.watch(heartbeat)
+
+	return ew, nil
+}
+
+// watch sends heartbeats and closes the stream when the request context is done.
+func (ew *EventWriter) watch(heartbeat time.Duration) {
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			ew.mu.Lock()
+			if !ew.closed {
+				if _, err := io.WriteString(ew.w, ": heartbeat\n\n"); err == nil {
+					ew.flusher.Flush()
+				}
+			}
+			ew.mu.Unlock()
+		case <-ew.r.Context().Done():
+			ew.Close()
+			return
+		case <-ew.done:
+			return
+		}
+	}
+}
+
+// LastEventID returns the ID of the last event the client received before reconnecting,
+// so the handler can resume the stream after it.
+func (ew *EventWriter) LastEventID() string {
+	if id := ew.r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return ew.r.URL.Query().Get("lastEventId")
+}
+
+// Done returns a channel that is closed when the stream stops.
+func (ew *EventWriter) Done() <-chan struct{} {
+	return ew.done
+}
+
+// Send encodes and writes the event, then flushes it to the client.
+// It returns ErrMultilineField if the ID or the Event contains CR or LF, and an error once the stream has stopped.
+func (ew *EventWriter) Send(ev *Event) error {
+	if strings.ContainsAny(ev.ID, "\r\n") || strings.ContainsAny(ev.Event, "\r\n") {
+		return ErrMultilineField
+	}
+
+	buf := new(bytes.Buffer)
+	if ev.ID != "" {
+		writeField(buf, "id", []byte(ev.ID))
+	}
+	if ev.Event != "" {
+		writeField(buf, "event", []byte(ev.Event))
+	}
+	if ev.Retry > 0 {
+		writeField(buf, "retry", []byte(strconv.FormatInt(ev.Retry.Milliseconds(), 10)))
+	}
+	if ev.Data != nil {
+		data := new(bytes.Buffer)
+		if err := ew.coder.Encode(data, ev.Data); err != nil {
+			return err
+		}
+		// Each line of the data is a separate field; the client joins them with newlines.
+		// CRLF, CR and LF all end a line in the stream, so a lone CR must not pass through either.
+		lines := bytes.ReplaceAll(data.Bytes(), []byte("\r\n"), []byte("\n"))
+		lines = bytes.ReplaceAll(lines, []byte("\r"), []byte("\n"))
+		for _, line := range bytes.Split(bytes.TrimSuffix(lines, []byte("\n")), []byte("\n")) {
+			writeField(buf, "data", line)
+		}
+	}
+	buf.WriteByte('\n')
+
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	if ew.closed {
+		if err := ew.r.Context().Err(); err != nil {
+			return err
+		}
+		return io.ErrClosedPipe
+	}
+	if _, err := buf.WriteTo(ew.w); err != nil {
+		return err
+	}
+	ew.flusher.Flush()
+
+	return nil
+}
+
+// Close stops the stream. The handler should return afterwards to end the response.
+func (ew *EventWriter) Close() {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	if !ew.closed {
+		ew.closed = true
+		close(ew.done)
+	}
+}
+
+func writeField(buf *bytes.Buffer, name string, value []byte) {
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.Write(value)
+	buf.WriteByte('\n')
+}
+
+// findFlusher returns the http.Flusher of w or of a writer it wraps.
+func findFlusher(w http.ResponseWriter) http.Flusher {
+	for {
+		if f, ok := w.(http.Flusher); ok {
+			return f
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+}
@@ -0,0 +1,79 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/coder"
+	"github.com/gromey/proto-rest/server"
+)
+
+func TestNewEventWriter(t *testing.T) {
+	srv := server.New(cdrJSON)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew, err := server.NewEventWriter(w, r, srv, 0)
+		equal(t, nil, err)
+		defer ew.Close()
+
+		equal(t, "41", ew.LastEventID())
+
+		equal(t, nil, ew.Send(&server.Event{ID: "42", Event: "update", Retry: 3 * time.Second, Data: &exampleStructClt{Field: "example"}}))
+		equal(t, nil, ew.Send(&server.Event{Data: "a"}))
+
+		// A line break would inject fields into the stream, the event is not sent.
+		equal(t, server.ErrMultilineField, ew.Send(&server.Event{ID: "1\nevent: forged", Data: "b"}))
+		equal(t, server.ErrMultilineField, ew.Send(&server.Event{Event: "update\rdata: forged", Data: "b"}))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	equal(t, nil, err)
+	req.Header.Set("Last-Event-ID", "41")
+
+	resp, err := http.DefaultClient.Do(req)
+	equal(t, nil, err)
+	defer resp.Body.Close()
+
+	equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	b, err := io.ReadAll(resp.Body)
+	equal(t, nil, err)
+	equal(t, "id: 42\nevent: update\nretry: 3000\ndata: {\"Field\":\"example\"}\n\ndata: \"a\"\n\n", string(b))
+}
+
+func TestEventWriter_Heartbeat(t *testing.T) {
+	srv := server.New(cdrJSON)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	ew, err := server.NewEventWriter(rec, req, srv, 10*time.Millisecond)
+	equal(t, nil, err)
+	time.Sleep(35 * time.Millisecond)
+	ew.Close()
+
+	<-ew.Done()
+	equal(t, true, strings.Contains(rec.Body.String(), ": heartbeat\n\n"))
+	equal(t, io.ErrClosedPipe, ew.Send(&server.Event{Data: "late"}))
+}
+
+func TestEventWriter_LineBreaksInData(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	ew, err := server.NewEventWriter(rec, req, coder.NewTextCoder(), 0)
+	equal(t, nil, err)
+	defer ew.Close()
+
+	// A CR ends a line in the stream, so it must not inject fields.
+	equal(t, nil, ew.Send(&server.Event{Data: "hello\rid: injected\revent: evil"}))
+	equal(t, nil, ew.Send(&server.Event{Data: "a\r\nb\nc\n"}))
+
+	equal(t, "data: hello\ndata: id: injected\ndata: event: evil\n\ndata: a\ndata: b\ndata: c\n\n", rec.Body.String())
+}
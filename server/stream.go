@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gromey/proto-rest/coder"
+)
+
+// StreamContentType is the content type of newline-delimited streams.
+const StreamContentType = "application/x-ndjson"
+
+// ErrMultilineItem is returned when an encoded item of a stream contains a newline.
+var ErrMultilineItem = errors.New("server: encoded stream item contains a newline")
+
+// StreamWriter writes items of a newline-delimited (NDJSON, JSON Lines) response.
+// It is safe for concurrent use.
+type StreamWriter struct {
+	w        http.ResponseWriter
+	flusher  http.Flusher
+	coder    coder.Coder
+	r        *http.Request
+	interval time.Duration
+
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	lastFlush time.Time
+	pending   bool // Items were written since the last flush.
+	done      chan struct{}
+	closed    bool
+}
+
+// NewStreamWriter starts a newline-delimited response with statusCode. Each item is encoded with c, e.g. a Server,
+// which must not produce newlines inside an item. The response is flushed to the client every flushInterval,
+// even while no items are written, or on every item if it is zero.
+// Writes fail once the request context is done. The handler must call Close before it returns.
+func NewStreamWriter(w http.ResponseWriter, r *http.Request, c coder.Coder, statusCode int, flushInterval time.Duration) *StreamWriter {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", StreamContentType)
+	}
+	w.Header().Del("Content-Length")
+	w.WriteHeader(statusCode)
+
+	sw := &StreamWriter{
+		w:         w,
+		flusher:   findFlusher(w),
+		coder:     c,
+		r:         r,
+		interval:  flushInterval,
+		lastFlush: time.Now(),
+		done:      make(chan struct{}),
+	}
+
+	if flushInterval > 0 && sw.flusher != nil {
+		go sw.watch()
+	}
+
+	return sw
+}
+
+// watch flushes the items buffered by the response writer every interval, so they don't wait for the next item.
+func (sw *StreamWriter) watch() {
+	ticker := time.NewTicker(sw.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sw.mu.Lock()
+			if !sw.closed && sw.pending {
+				sw.flushLocked()
+			}
+			sw.mu.Unlock()
+		case <-sw.done:
+			return
+		}
+	}
+}
+
+// Write encodes v and writes it followed by a newline.
+// It returns the context error once the request context is done, and an error once the stream is closed.
+func (sw *StreamWriter) Write(v any) error {
+	if err := sw.r.Context().Err(); err != nil {
+		return err
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if sw.closed {
+		return io.ErrClosedPipe
+	}
+
+	sw.buf.Reset()
+	if err := sw.coder.Encode(&sw.buf, v); err != nil {
+		return err
+	}
+	item := bytes.TrimRight(sw.buf.Bytes(), "\r\n")
+	if bytes.IndexByte(item, '\n') >= 0 {
+		return ErrMultilineItem
+	}
+
+	if _, err := sw.w.Write(append(item, '\n')); err != nil {
+		return err
+	}
+	sw.pending = true
+
+	if time.Since(sw.lastFlush) >= sw.interval {
+		sw.flushLocked()
+	}
+
+	return nil
+}
+
+// Flush sends the buffered items to the client.
+func (sw *StreamWriter) Flush() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if !sw.closed {
+		sw.flushLocked()
+	}
+}
+
+// Close flushes the buffered items and stops the periodic flushing. Writes fail afterwards.
+func (sw *StreamWriter) Close() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if !sw.closed {
+		sw.flushLocked()
+		sw.closed = true
+		close(sw.done)
+	}
+}
+
+func (sw *StreamWriter) flushLocked() {
+	if sw.flusher != nil {
+		sw.flusher.Flush()
+	}
+	sw.lastFlush = time.Now()
+	sw.pending = false
+}
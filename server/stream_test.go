@@ -0,0 +1,75 @@
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/coder"
+	"github.com/gromey/proto-rest/server"
+)
+
+func TestNewStreamWriter(t *testing.T) {
+	srv := server.New(cdrJSON)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	sw := server.NewStreamWriter(rec, req, srv, http.StatusOK, 0)
+	equal(t, nil, sw.Write(&exampleStructClt{Field: "a"}))
+	equal(t, nil, sw.Write(&exampleStructClt{Field: "b"}))
+	sw.Close()
+	equal(t, io.ErrClosedPipe, sw.Write(&exampleStructClt{Field: "c"}))
+
+	equal(t, http.StatusOK, rec.Code)
+	equal(t, true, rec.Flushed)
+	equal(t, server.StreamContentType, rec.Header().Get("Content-Type"))
+	equal(t, "{\"Field\":\"a\"}\n{\"Field\":\"b\"}\n", rec.Body.String())
+}
+
+func TestStreamWriter_Errors(t *testing.T) {
+	indent := coder.NewCoder("application/json", func(v any) ([]byte, error) {
+		return json.MarshalIndent(v, "", "  ")
+	}, json.Unmarshal)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	sw := server.NewStreamWriter(rec, req, indent, http.StatusOK, 0)
+	equal(t, server.ErrMultilineItem, sw.Write(&exampleStructClt{Field: "a"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sw = server.NewStreamWriter(httptest.NewRecorder(), req.WithContext(ctx), cdrJSON, http.StatusOK, 0)
+	equal(t, context.Canceled, sw.Write(&exampleStructClt{Field: "a"}))
+}
+
+func TestStreamWriter_FlushWhileIdle(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := server.NewStreamWriter(w, r, cdrJSON, http.StatusOK, 20*time.Millisecond)
+		defer sw.Close()
+
+		equal(t, nil, sw.Write(&exampleStructClt{Field: "a"}))
+		// No more items are written, the ticker has to flush the first one.
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	equal(t, nil, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	equal(t, nil, err)
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	equal(t, nil, err)
+	equal(t, "{\"Field\":\"a\"}\n", line)
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheControl represents the directives of a Cache-Control header.
+type CacheControl map[string]string
+
+// ParseCacheControl parses the values of a Cache-Control header into directives.
+// Directive names are lower-cased, quoted values are unquoted.
+func ParseCacheControl(values []string) CacheControl {
+	cc := make(CacheControl)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, arg, _ := strings.Cut(part, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+		}
+	}
+	return cc
+}
+
+// Has reports whether the directive is present.
+func (cc CacheControl) Has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// Duration returns the value of a delta-seconds directive such as max-age.
+func (cc CacheControl) Duration(directive string) (time.Duration, bool) {
+	v, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// FormatTimeout formats d as a grpc-timeout style value: at most 8 digits followed by a unit
+// (H, M, S, m, u or n), e.g. "1500m" for 1.5 seconds.
+func FormatTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "0n"
+	}
+	units := [...]struct {
+		unit string
+		d    time.Duration
+	}{
+		{"n", time.Nanosecond},
+		{"u", time.Microsecond},
+		{"m", time.Millisecond},
+		{"S", time.Second},
+		{"M", time.Minute},
+		{"H", time.Hour},
+	}
+	for _, u := range units {
+		// Round down, so the budget is never reported longer than it is.
+		if v := d / u.d; v < 1e8 {
+			return strconv.FormatInt(int64(v), 10) + u.unit
+		}
+	}
+	return "99999999H"
+}
+
+// ParseTimeout parses a grpc-timeout style value produced by FormatTimeout.
+func ParseTimeout(s string) (time.Duration, error) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, fmt.Errorf("invalid timeout %q", s)
+	}
+	v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", s)
+	}
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, fmt.Errorf("invalid timeout unit %q", s)
+	}
+	if v > math.MaxInt64/int64(unit) {
+		// Longer than a time.Duration can hold.
+		return math.MaxInt64, nil
+	}
+	return time.Duration(v) * unit, nil
+}
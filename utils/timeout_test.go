@@ -0,0 +1,65 @@
+package utils_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gromey/proto-rest/utils"
+)
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+func TestFormatTimeout(t *testing.T) {
+	var tests = []struct {
+		d   time.Duration
+		exp string
+	}{
+		{d: 0, exp: "0n"},
+		{d: 1500 * time.Millisecond, exp: "1500000u"},
+		{d: 99999999 * time.Nanosecond, exp: "99999999n"},
+		{d: 100000001 * time.Nanosecond, exp: "100000u"},
+		{d: 99999999*time.Second + time.Millisecond, exp: "99999999S"},
+		{d: math.MaxInt64, exp: "2562047H"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.exp, func(t *testing.T) {
+			s := utils.FormatTimeout(test.d)
+			equal(t, test.exp, s)
+
+			// The formatted budget is never longer than the duration and is always parsable.
+			d, err := utils.ParseTimeout(s)
+			equal(t, nil, err)
+			equal(t, true, d <= test.d)
+		})
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	var tests = []struct {
+		s      string
+		exp    time.Duration
+		expErr bool
+	}{
+		{s: "0S", exp: 0},
+		{s: "10m", exp: 10 * time.Millisecond},
+		{s: "99999999H", exp: math.MaxInt64},
+		{s: "100000000S", expErr: true},
+		{s: "-1S", expErr: true},
+		{s: "1x", expErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.s, func(t *testing.T) {
+			d, err := utils.ParseTimeout(test.s)
+			equal(t, test.expErr, err != nil)
+			equal(t, test.exp, d)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gromey/proto-rest/coder"
+)
+
+// Dial opens a WebSocket connection to rawURL (ws, wss, http or https scheme) through the transport of hc,
+// so its proxy, TLS and dialer settings and any round trippers apply to the handshake.
+// Messages are encoded and decoded with c. To add headers to the handshake request, use the optional function f.
+func Dial(ctx context.Context, hc *http.Client, rawURL string, c coder.Coder, opts *Options, f func(*http.Request)) (*Conn, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	switch {
+	case strings.HasPrefix(rawURL, "ws://"):
+		rawURL = "http://" + rawURL[len("ws://"):]
+	case strings.HasPrefix(rawURL, "wss://"):
+		rawURL = "https://" + rawURL[len("wss://"):]
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var k [16]byte
+	if _, err = rand.Read(k[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(k[:])
+
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	if len(opts.Subprotocols) != 0 {
+		request.Header.Set("Sec-WebSocket-Protocol", strings.Join(opts.Subprotocols, ", "))
+	}
+	if hc.Jar != nil {
+		for _, cookie := range hc.Jar.Cookies(request.URL) {
+			request.AddCookie(cookie)
+		}
+	}
+
+	if f != nil {
+		f(request)
+	}
+
+	// The transport is used directly: the client timeout would also limit the lifetime of the connection.
+	resp, err := handshakeTransport(hc).RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if resp.StatusCode != http.StatusSwitchingProtocols || !ok ||
+		!headerContainsToken(resp.Header, "Upgrade", "websocket") ||
+		!headerContainsToken(resp.Header, "Connection", "upgrade") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrBadHandshake, resp.Status)
+	}
+
+	subprotocol := resp.Header.Get("Sec-WebSocket-Protocol")
+	if subprotocol != "" && !contains(opts.Subprotocols, subprotocol) {
+		_ = rwc.Close()
+		return nil, fmt.Errorf("%w: unexpected subprotocol %q", ErrBadHandshake, subprotocol)
+	}
+
+	return newConn(rwc, bufio.NewReader(rwc), true, c, opts, subprotocol), nil
+}
+
+// handshakeTransport returns the transport of hc. A *http.Transport is cloned with HTTP/2 disabled,
+// because a connection can only be upgraded over HTTP/1.1.
+func handshakeTransport(hc *http.Client) http.RoundTripper {
+	rt := hc.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return rt
+	}
+
+	t = t.Clone()
+	t.ForceAttemptHTTP2 = false
+	t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	if t.TLSClientConfig != nil {
+		var protos []string
+		for _, p := range t.TLSClientConfig.NextProtos {
+			if p != "h2" {
+				protos = append(protos, p)
+			}
+		}
+		t.TLSClientConfig.NextProtos = protos
+	}
+	return t
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,447 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gromey/proto-rest/coder"
+)
+
+// Close codes defined in RFC 6455, section 7.4.1.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005 // Never sent, reported when the close frame has no code.
+	CloseAbnormalClosure         = 1006 // Never sent, reported when the connection was lost without a close frame.
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	finBit  = 0x80
+	rsvBits = 0x70
+	maskBit = 0x80
+
+	maxControlPayload     = 125
+	defaultMaxMessageSize = 1 << 20
+)
+
+// ErrCloseSent is returned when a message is written after the close frame was sent.
+var ErrCloseSent = errors.New("websocket: close frame sent")
+
+// CloseError is returned by ReadMessage when the connection is closed with a close frame,
+// sent by the peer or by this side after a protocol violation.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("websocket: close %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: close %d: %s", e.Code, e.Text)
+}
+
+// Options represents options for configuring a Conn.
+type Options struct {
+	Subprotocols   []string                   // Supported subprotocols by preference on the server, requested subprotocols on the client.
+	MaxMessageSize int64                      // The maximum size of a received message. Defaults to 1 MiB, larger messages close the connection with 1009.
+	Binary         bool                       // Sends messages in binary frames even if the coder produces text.
+	PingInterval   time.Duration              // Sends a ping at this interval and drops the connection when nothing is received for two intervals. Requires a read loop.
+	WriteTimeout   time.Duration              // The deadline of a single write, if the connection supports deadlines.
+	CheckOrigin    func(r *http.Request) bool // Accepts the handshake on the server. Defaults to requests without Origin or with an Origin matching Host.
+}
+
+// Conn represents a WebSocket connection. Messages are encoded and decoded with a Coder.
+// ReadMessage must be called from one goroutine at a time; the other methods are safe for concurrent use.
+// Control frames, such as pongs and close frames, are only processed while ReadMessage is called, so a connection
+// that only writes must still read in a goroutine, otherwise a PingInterval drops it.
+type Conn struct {
+	coder       coder.Coder
+	opts        *Options
+	dataOp      byte // The opcode of written messages.
+	rwc         io.ReadWriteCloser
+	br          *bufio.Reader
+	client      bool
+	subprotocol string
+
+	readErr  error
+	lastRead int64
+
+	wmu       sync.Mutex
+	closeSent bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newConn(rwc io.ReadWriteCloser, br *bufio.Reader, client bool, c coder.Coder, opts *Options, subprotocol string) *Conn {
+	dataOp := byte(opBinary)
+	if !opts.Binary && textContentType(c.ContentType()) {
+		dataOp = opText
+	}
+	conn := &Conn{
+		coder:       c,
+		opts:        opts,
+		dataOp:      dataOp,
+		rwc:         rwc,
+		br:          br,
+		client:      client,
+		subprotocol: subprotocol,
+		lastRead:    time.Now().UnixNano(),
+		done:        make(chan struct{}),
+	}
+	if opts.PingInterval > 0 {
+		go conn.keepalive(opts.PingInterval)
+	}
+	return conn
+}
+
+// Subprotocol returns the subprotocol negotiated during the handshake.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// ReadMessage reads the next message and decodes it into the value pointed to by v.
+// Pings are answered while waiting. When the connection is closed with a close frame
+// it returns a *CloseError, and every later call returns the same error.
+func (c *Conn) ReadMessage(v any) error {
+	p, err := c.readMessage()
+	if err != nil {
+		return err
+	}
+	return c.coder.Decode(bytes.NewReader(p), v)
+}
+
+// WriteMessage encodes v and writes it as a single message. Messages are sent in text frames
+// if the content type of the Coder is text, JSON or XML, and in binary frames otherwise.
+func (c *Conn) WriteMessage(v any) error {
+	buf := new(bytes.Buffer)
+	if err := c.coder.Encode(buf, v); err != nil {
+		return err
+	}
+	return c.writeFrame(c.dataOp, buf.Bytes())
+}
+
+// Ping sends a ping with the optional payload of up to 125 bytes.
+func (c *Conn) Ping(payload []byte) error {
+	if len(payload) > maxControlPayload {
+		return errors.New("websocket: control frame payload too large")
+	}
+	return c.writeFrame(opPing, payload)
+}
+
+// Close sends a normal closure frame and closes the connection.
+func (c *Conn) Close() error {
+	return c.CloseWithStatus(CloseNormalClosure, "")
+}
+
+// CloseWithStatus sends a close frame with the code and reason and closes the connection.
+func (c *Conn) CloseWithStatus(code int, text string) error {
+	_ = c.writeClose(code, text)
+
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.rwc.Close()
+	})
+	return err
+}
+
+func (c *Conn) readMessage() ([]byte, error) {
+	if c.readErr != nil {
+		return nil, c.readErr
+	}
+
+	limit := c.opts.MaxMessageSize
+	if limit <= 0 {
+		limit = defaultMaxMessageSize
+	}
+
+	var msg []byte
+	var msgOp byte
+	for {
+		fin, op, payload, err := c.readFrame(limit - int64(len(msg)))
+		if err != nil {
+			c.readErr = err
+			return nil, err
+		}
+
+		switch op {
+		case opPing:
+			if err = c.writeFrame(opPong, payload); err != nil && err != ErrCloseSent {
+				c.readErr = err
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.readErr = c.handleClose(payload)
+			return nil, c.readErr
+		case opContinuation:
+			if msgOp == 0 {
+				c.readErr = c.fail(CloseProtocolError, "unexpected continuation frame")
+				return nil, c.readErr
+			}
+		case opText, opBinary:
+			if msgOp != 0 {
+				c.readErr = c.fail(CloseProtocolError, "expected continuation frame")
+				return nil, c.readErr
+			}
+			msgOp = op
+		default:
+			c.readErr = c.fail(CloseProtocolError, "unknown opcode")
+			return nil, c.readErr
+		}
+
+		msg = append(msg, payload...)
+		if fin {
+			if msgOp == opText && !utf8.Valid(msg) {
+				c.readErr = c.fail(CloseInvalidFramePayloadData, "invalid UTF-8 in text message")
+				return nil, c.readErr
+			}
+			return msg, nil
+		}
+	}
+}
+
+// readFrame reads a single frame. A data frame payload larger than limit fails the connection.
+func (c *Conn) readFrame(limit int64) (fin bool, op byte, payload []byte, err error) {
+	var h [8]byte
+	if _, err = io.ReadFull(c.br, h[:2]); err != nil {
+		return
+	}
+	atomic.StoreInt64(&c.lastRead, time.Now().UnixNano())
+
+	fin = h[0]&finBit != 0
+	op = h[0] & 0x0f
+	masked := h[1]&maskBit != 0
+
+	if h[0]&rsvBits != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	// Clients must mask every frame, servers must not mask any.
+	if masked == c.client {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid frame masking")
+	}
+
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		if _, err = io.ReadFull(c.br, h[:2]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(h[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.br, h[:8]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(h[:8])
+		if n>>63 != 0 {
+			return false, 0, nil, c.fail(CloseProtocolError, "invalid payload length")
+		}
+	}
+
+	if op >= opClose {
+		if !fin || n > maxControlPayload {
+			return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+		}
+	} else if n > uint64(limit) {
+		return false, 0, nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, key[:]); err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(key, payload)
+	}
+
+	return fin, op, payload, nil
+}
+
+// handleClose answers a close frame and returns the error that reports it.
+func (c *Conn) handleClose(payload []byte) error {
+	code, text := CloseNoStatusReceived, ""
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		code = int(binary.BigEndian.Uint16(payload))
+		text = string(payload[2:])
+		if !validCloseCode(code) {
+			return c.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(text) {
+			return c.fail(CloseInvalidFramePayloadData, "invalid UTF-8 in close reason")
+		}
+	}
+
+	// Echo the code back to complete the closing handshake.
+	if code == CloseNoStatusReceived {
+		_ = c.writeFrame(opClose, nil)
+	} else {
+		_ = c.writeClose(code, "")
+	}
+
+	return &CloseError{Code: code, Text: text}
+}
+
+// fail sends a close frame with code and returns the error that reports it.
+func (c *Conn) fail(code int, text string) error {
+	_ = c.writeClose(code, text)
+	return &CloseError{Code: code, Text: text}
+}
+
+func (c *Conn) writeClose(code int, text string) error {
+	if len(text) > maxControlPayload-2 {
+		text = text[:maxControlPayload-2]
+	}
+	payload := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], text)
+	return c.writeFrame(opClose, payload)
+}
+
+// writeFrame writes a single final frame. After a close frame nothing else is written.
+func (c *Conn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if op == opClose {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, finBit|op)
+
+	var maskFlag byte
+	if c.client {
+		maskFlag = maskBit
+	}
+
+	n := len(payload)
+	switch {
+	case n <= 125:
+		frame = append(frame, maskFlag|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskFlag|126, byte(n>>8), byte(n))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(frame, maskFlag|127)
+		frame = append(frame, ext[:]...)
+	}
+
+	if c.client {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		frame = append(frame, key[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(key, frame[start:])
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	if d, ok := c.rwc.(interface{ SetWriteDeadline(time.Time) error }); ok && c.opts.WriteTimeout > 0 {
+		_ = d.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout))
+	}
+
+	_, err := c.rwc.Write(frame)
+	return err
+}
+
+// keepalive sends pings and drops the connection when the peer stops responding.
+func (c *Conn) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if time.Since(time.Unix(0, atomic.LoadInt64(&c.lastRead))) > 2*interval {
+				c.closeOnce.Do(func() {
+					close(c.done)
+					_ = c.rwc.Close()
+				})
+				return
+			}
+			if err := c.writeFrame(opPing, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// textContentType reports whether a coder with contentType produces UTF-8 text that may be sent in text frames.
+// An empty content type is treated as text.
+func textContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011, code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package websocket_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gromey/proto-rest/coder"
+	"github.com/gromey/proto-rest/logger"
+	"github.com/gromey/proto-rest/websocket"
+)
+
+func init() {
+	logger.SetLogger(logger.New(nil))
+}
+
+func equal(t *testing.T, exp, got any) {
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("Not equal:\nexp: %v\ngot: %v", exp, got)
+	}
+}
+
+var cdrJSON = coder.NewCoder("application/json", json.Marshal, json.Unmarshal)
+
+type message struct {
+	Text string
+}
+
+func wsURL(ts *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func closeCode(err error) int {
+	var ce *websocket.CloseError
+	if errors.As(err, &ce) {
+		return ce.Code
+	}
+	return 0
+}
+
+func TestConn_Echo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r, cdrJSON, &websocket.Options{Subprotocols: []string{"v2", "v1"}})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			msg := new(message)
+			if err = conn.ReadMessage(msg); err != nil {
+				return
+			}
+			msg.Text = strings.ToUpper(msg.Text)
+			if err = conn.WriteMessage(msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := websocket.Dial(ctx, http.DefaultClient, wsURL(ts), cdrJSON, &websocket.Options{Subprotocols: []string{"v1", "v2"}}, nil)
+	equal(t, nil, err)
+	// The connection outlives the context of the handshake.
+	cancel()
+
+	equal(t, "v2", conn.Subprotocol())
+	equal(t, nil, conn.Ping([]byte("ping")))
+
+	for _, text := range []string{"hello", strings.Repeat("a", 70000)} {
+		equal(t, nil, conn.WriteMessage(&message{Text: text}))
+		got := new(message)
+		equal(t, nil, conn.ReadMessage(got))
+		equal(t, strings.ToUpper(text), got.Text)
+	}
+
+	equal(t, nil, conn.Close())
+	equal(t, websocket.ErrCloseSent, conn.WriteMessage(&message{Text: "late"}))
+}
+
+func TestConn_BinaryCoder(t *testing.T) {
+	cdrBytes := coder.NewBytesCoder()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r, cdrBytes, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var msg []byte
+		if err = conn.ReadMessage(&msg); err != nil {
+			return
+		}
+		_ = conn.WriteMessage(msg)
+	}))
+	defer ts.Close()
+
+	conn, err := websocket.Dial(context.Background(), http.DefaultClient, wsURL(ts), cdrBytes, nil, nil)
+	equal(t, nil, err)
+	defer conn.Close()
+
+	// Invalid UTF-8 fails the connection if it is sent in a text frame.
+	equal(t, nil, conn.WriteMessage([]byte{0xff, 0xfe, 0x00}))
+	var got []byte
+	equal(t, nil, conn.ReadMessage(&got))
+	equal(t, []byte{0xff, 0xfe, 0x00}, got)
+}
+
+func TestConn_Close(t *testing.T) {
+	errChan := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r, cdrJSON, &websocket.Options{MaxMessageSize: 16})
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer conn.Close()
+		errChan <- conn.ReadMessage(new(message))
+	}))
+	defer ts.Close()
+
+	var tests = []struct {
+		name    string
+		send    func(conn *websocket.Conn) error
+		expCode int
+	}{
+		{
+			name:    "closed by the client",
+			send:    func(conn *websocket.Conn) error { return conn.CloseWithStatus(websocket.CloseGoingAway, "bye") },
+			expCode: websocket.CloseGoingAway,
+		},
+		{
+			name:    "message too big",
+			send:    func(conn *websocket.Conn) error { return conn.WriteMessage(&message{Text: "too long for the limit"}) },
+			expCode: websocket.CloseMessageTooBig,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, err := websocket.Dial(context.Background(), http.DefaultClient, wsURL(ts), cdrJSON, nil, nil)
+			equal(t, nil, err)
+			defer conn.Close()
+
+			equal(t, nil, test.send(conn))
+			equal(t, test.expCode, closeCode(<-errChan))
+
+			if test.expCode == websocket.CloseMessageTooBig {
+				// The server closes the connection with the same code.
+				equal(t, test.expCode, closeCode(conn.ReadMessage(new(message))))
+			}
+		})
+	}
+}
+
+func TestUpgrade_BadHandshake(t *testing.T) {
+	valid := map[string]string{
+		"Connection":            "keep-alive, Upgrade",
+		"Upgrade":               "websocket",
+		"Sec-WebSocket-Version": "13",
+		"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+	}
+
+	var tests = []struct {
+		name      string
+		method    string
+		header    map[string]string
+		expStatus int
+	}{
+		{name: "method", method: http.MethodPost, expStatus: http.StatusMethodNotAllowed},
+		{name: "no upgrade", method: http.MethodGet, header: map[string]string{"Upgrade": ""}, expStatus: http.StatusUpgradeRequired},
+		{name: "version", method: http.MethodGet, header: map[string]string{"Sec-WebSocket-Version": "8"}, expStatus: http.StatusUpgradeRequired},
+		{name: "key", method: http.MethodGet, header: map[string]string{"Sec-WebSocket-Key": "short"}, expStatus: http.StatusBadRequest},
+		{name: "origin", method: http.MethodGet, header: map[string]string{"Origin": "https://evil.example"}, expStatus: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "http://example.com/ws", nil)
+			for k, v := range valid {
+				req.Header.Set(k, v)
+			}
+			for k, v := range test.header {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			_, err := websocket.Upgrade(rec, req, cdrJSON, nil)
+			equal(t, websocket.ErrBadHandshake, err)
+			equal(t, test.expStatus, rec.Code)
+		})
+	}
+}